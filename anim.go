@@ -0,0 +1,444 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Animated GIF / APNG player
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Frame disposal after it's displayed
+const (
+	disposeNone       = iota // keep the frame
+	disposeBackground        // clear the frame area to transparent
+	disposePrevious          // restore the area before the frame
+)
+
+// Default delay for the frames with no or too short (10ms or less)
+// delay, same as browsers
+const (
+	defaultFrameDelay = 100 * time.Millisecond
+	minFrameDelay     = 10 * time.Millisecond
+)
+
+// Limits of the canvas size, the canvas and frames are allocated for it
+const (
+	animMaxSize   = 1 << 16 // maximum width and height
+	animMaxPixels = 1 << 26 // maximum pixels of the canvas
+)
+
+// Animation is a sequence of the frames converted for the display
+type Animation struct {
+	wsd    *WsDisplay
+	width  int
+	height int
+	frames []PIXELARRAY
+	delays []time.Duration
+	plays  int // number of plays, 0 is forever
+}
+
+// Load animated GIF or APNG. A still PNG is loaded as one frame animation.
+func (wsd *WsDisplay) LoadAnimation(r io.Reader) (*Animation, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(8)
+	if err != nil {
+		return nil, err
+	}
+	if string(magic[:4]) == "GIF8" {
+		return wsd.LoadGIF(br)
+	}
+	if string(magic) == pngHeader {
+		return wsd.LoadAPNG(br)
+	}
+	return nil, errors.New("Unsupported animation format")
+}
+
+// Load animated GIF
+func (wsd *WsDisplay) LoadGIF(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	w := g.Config.Width
+	h := g.Config.Height
+	if w == 0 || h == 0 {
+		for _, f := range g.Image {
+			if f.Rect.Max.X > w {
+				w = f.Rect.Max.X
+			}
+			if f.Rect.Max.Y > h {
+				h = f.Rect.Max.Y
+			}
+		}
+	}
+	if err := checkAnimSize(w, h); err != nil {
+		return nil, err
+	}
+	a := &Animation{wsd: wsd, width: w, height: h}
+	switch {
+	case g.LoopCount == 0:
+		a.plays = 0
+	case g.LoopCount < 0:
+		a.plays = 1
+	default:
+		a.plays = g.LoopCount + 1
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, f := range g.Image {
+		dispose := disposeNone
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				dispose = disposeBackground
+			case gif.DisposalPrevious:
+				dispose = disposePrevious
+			}
+		}
+		delay := time.Duration(0)
+		if i < len(g.Delay) {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		if delay <= minFrameDelay {
+			delay = defaultFrameDelay
+		}
+		err := a.addFrame(canvas, f, f.Rect, true, dispose, delay)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Composite the frame to the canvas, store it and dispose
+func (a *Animation) addFrame(canvas *image.RGBA, frame image.Image, rect image.Rectangle,
+	over bool, dispose int, delay time.Duration) error {
+	var prev *image.RGBA
+	if dispose == disposePrevious {
+		prev = image.NewRGBA(canvas.Rect)
+		copy(prev.Pix, canvas.Pix)
+	}
+	op := draw.Over
+	if !over {
+		op = draw.Src
+	}
+	draw.Draw(canvas, rect, frame, frame.Bounds().Min, op)
+
+	p, err := a.wsd.NewPixelArray()
+	if err != nil {
+		return err
+	}
+	a.wsd.storeImage(p, canvas)
+	a.frames = append(a.frames, p)
+	a.delays = append(a.delays, delay)
+
+	switch dispose {
+	case disposeBackground:
+		draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+	case disposePrevious:
+		copy(canvas.Pix, prev.Pix)
+	}
+	return nil
+}
+
+func (a *Animation) GetWidth() int {
+	return a.width
+}
+
+func (a *Animation) GetHeight() int {
+	return a.height
+}
+
+// Number of the frames
+func (a *Animation) Len() int {
+	return len(a.frames)
+}
+
+// Get the frame i and its delay
+func (a *Animation) Frame(i int) (PIXELARRAY, time.Duration) {
+	return a.frames[i], a.delays[i]
+}
+
+// Number of plays, 0 is forever
+func (a *Animation) GetPlays() int {
+	return a.plays
+}
+
+// Set the number of plays, 0 is forever
+func (a *Animation) SetPlays(n int) {
+	a.plays = n
+}
+
+// Play the animation at (x, y) until the number of plays is done or
+// ctx is canceled. It returns ctx.Err() if it's canceled.
+// The transparent area of the frames shows the screen before playing.
+func (a *Animation) Play(ctx context.Context, x int, y int) error {
+	if len(a.frames) == 0 {
+		return nil
+	}
+	bg, err := a.wsd.GetPixelArray(image.Rect(x, y, x+a.width, y+a.height))
+	if err != nil {
+		return err
+	}
+	buf, err := a.wsd.newPixelArraySize(a.width, a.height)
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	next := time.Now()
+	for n := 0; a.plays == 0 || n < a.plays; n++ {
+		for i, f := range a.frames {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			overBackground(buf, bg, f)
+			a.wsd.putPixelArrayRectMasked(image.Pt(x, y), buf, image.Rect(0, 0, a.width, a.height))
+			// schedule from the start of the frame to avoid the drift
+			next = next.Add(a.delays[i])
+			d := time.Until(next)
+			if d < 0 {
+				next = time.Now()
+				d = 0
+			}
+			timer.Reset(d)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return nil
+}
+
+// Make dst the frame f over the background bg, all of them are same size
+func overBackground(dst PIXELARRAY, bg PIXELARRAY, f PIXELARRAY) {
+	d, bpp := pixelArrayBytes(dst)
+	b, _ := pixelArrayBytes(bg)
+	s, _ := pixelArrayBytes(f)
+	copy(d, b)
+	dm := dst.GetMasks()
+	copy(dm, bg.GetMasks())
+	for i, m := range f.GetMasks() {
+		if m {
+			copy(d[i*bpp:(i+1)*bpp], s[i*bpp:(i+1)*bpp])
+			dm[i] = true
+		}
+	}
+}
+
+// APNG support; the frames are re-assembled to the standalone PNG streams
+// and decoded by image/png.
+
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+type pngChunk struct {
+	name string
+	data []byte
+}
+
+// APNG frame control
+type apngFrame struct {
+	width, height int
+	x, y          int
+	delay         time.Duration
+	dispose       int
+	over          bool
+	data          [][]byte // IDAT or fdAT (without sequence number) data
+}
+
+// Load APNG, a still PNG is loaded as one frame
+func (wsd *WsDisplay) LoadAPNG(r io.Reader) (*Animation, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || string(data[:8]) != pngHeader {
+		return nil, errors.New("Not PNG")
+	}
+	var chunks []pngChunk
+	for i := 8; i+12 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		if n < 0 || n > len(data)-i-12 {
+			return nil, errors.New("Broken PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{string(data[i+4 : i+8]), data[i+8 : i+8+n]})
+		i += 12 + n
+	}
+
+	var ihdr []byte
+	var extra []pngChunk // PLTE, tRNS and color space chunks
+	var frames []*apngFrame
+	var cur *apngFrame
+	plays := 1
+	animated := false
+	for _, c := range chunks {
+		switch c.name {
+		case "IHDR":
+			ihdr = c.data
+		case "PLTE", "tRNS", "gAMA", "cHRM", "sRGB", "iCCP", "sBIT":
+			extra = append(extra, c)
+		case "acTL":
+			if len(c.data) >= 8 {
+				animated = true
+				plays = int(binary.BigEndian.Uint32(c.data[4:]))
+			}
+		case "fcTL":
+			if len(c.data) < 26 {
+				return nil, errors.New("Broken fcTL chunk")
+			}
+			num := binary.BigEndian.Uint16(c.data[20:])
+			den := binary.BigEndian.Uint16(c.data[22:])
+			if den == 0 {
+				den = 100
+			}
+			cur = &apngFrame{
+				width:   int(binary.BigEndian.Uint32(c.data[4:])),
+				height:  int(binary.BigEndian.Uint32(c.data[8:])),
+				x:       int(binary.BigEndian.Uint32(c.data[12:])),
+				y:       int(binary.BigEndian.Uint32(c.data[16:])),
+				delay:   time.Duration(num) * time.Second / time.Duration(den),
+				dispose: int(c.data[24]),
+				over:    c.data[25] == 1,
+			}
+			frames = append(frames, cur)
+		case "IDAT":
+			if cur != nil {
+				cur.data = append(cur.data, c.data)
+			}
+		case "fdAT":
+			if cur != nil && len(c.data) > 4 {
+				cur.data = append(cur.data, c.data[4:])
+			}
+		}
+	}
+	if ihdr == nil || len(ihdr) < 13 {
+		return nil, errors.New("No IHDR chunk")
+	}
+	w := int(binary.BigEndian.Uint32(ihdr[0:]))
+	h := int(binary.BigEndian.Uint32(ihdr[4:]))
+	if err := checkAnimSize(w, h); err != nil {
+		return nil, err
+	}
+	for _, f := range frames {
+		if f.width <= 0 || f.height <= 0 || f.x < 0 || f.y < 0 ||
+			f.x > w-f.width || f.y > h-f.height {
+			return nil, errors.New("Broken fcTL chunk")
+		}
+		if f.delay <= minFrameDelay {
+			f.delay = defaultFrameDelay
+		}
+	}
+	a := &Animation{wsd: wsd, width: w, height: h, plays: plays}
+
+	if !animated || len(frames) == 0 {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+		err = a.addFrame(canvas, img, img.Bounds(), false, disposeNone, 0)
+		return a, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, f := range frames {
+		if len(f.data) == 0 {
+			continue
+		}
+		img, err := png.Decode(bytes.NewReader(buildPNG(ihdr, extra, f)))
+		if err != nil {
+			return nil, err
+		}
+		dispose := f.dispose
+		if i == 0 && dispose == disposePrevious {
+			dispose = disposeBackground
+		}
+		rect := image.Rect(f.x, f.y, f.x+f.width, f.y+f.height)
+		if err := a.addFrame(canvas, img, rect, f.over, dispose, f.delay); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Check the canvas size before allocating it
+func checkAnimSize(w int, h int) error {
+	if w <= 0 || h <= 0 {
+		return errors.New("Invalid image size")
+	}
+	if w > animMaxSize || h > animMaxSize || uint64(w)*uint64(h) > animMaxPixels {
+		return errors.New("Too large image")
+	}
+	return nil
+}
+
+// Build standalone PNG stream of the frame
+func buildPNG(ihdr []byte, extra []pngChunk, f *apngFrame) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(pngHeader)
+	hdr := append([]byte{}, ihdr...)
+	binary.BigEndian.PutUint32(hdr[0:], uint32(f.width))
+	binary.BigEndian.PutUint32(hdr[4:], uint32(f.height))
+	writePNGChunk(&buf, "IHDR", hdr)
+	for _, c := range extra {
+		writePNGChunk(&buf, c.name, c.data)
+	}
+	for _, d := range f.data {
+		writePNGChunk(&buf, "IDAT", d)
+	}
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+func writePNGChunk(buf *bytes.Buffer, name string, data []byte) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(data)))
+	buf.Write(b[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(name))
+	crc.Write(data)
+	buf.WriteString(name)
+	buf.Write(data)
+	binary.BigEndian.PutUint32(b[:], crc.Sum32())
+	buf.Write(b[:])
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for Animation
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// GIF of two 4x4 frames, a red dot at (n, n) on the transparent
+func testGIF(t *testing.T, delay int) []byte {
+	g := &gif.GIF{}
+	pal := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}}
+	for n := 0; n < 2; n++ {
+		img := image.NewPaletted(image.Rect(0, 0, 4, 4), pal)
+		img.SetColorIndex(n, n, 1)
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, delay)
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAnimationPlayTransparent(t *testing.T) {
+	wsd := newTestDisplay(t, 8, 8, 32)
+	green := wsd.NewPixel(color.RGBA{0, 255, 0, 255})
+	wsd.FillBox(image.Rect(0, 0, 8, 8), green)
+	a, err := wsd.LoadGIF(bytes.NewReader(testGIF(t, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetPlays(1)
+	if err := a.Play(context.Background(), 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := color.RGBA{0, 255, 0, 255}
+			if x == 3 && y == 3 {
+				// the dot of the last frame
+				want = color.RGBA{255, 0, 0, 255}
+			}
+			if got := wsd.pixelColor(wsd.GetPixel(x, y)); got != want {
+				t.Errorf("(%d, %d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestAnimationGIFDelay(t *testing.T) {
+	wsd := newTestDisplay(t, 8, 8, 32)
+	for _, tt := range []struct {
+		delay int
+		want  time.Duration
+	}{
+		{0, defaultFrameDelay},
+		{1, defaultFrameDelay},
+		{2, 20 * time.Millisecond},
+		{50, 500 * time.Millisecond},
+	} {
+		a, err := wsd.LoadGIF(bytes.NewReader(testGIF(t, tt.delay)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, d := a.Frame(0); d != tt.want {
+			t.Errorf("GIF delay %d: %v, want %v", tt.delay, d, tt.want)
+		}
+	}
+}
+
+// APNG of one frame with the delay num/den
+func testAPNG(t *testing.T, num uint16, den uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	var still bytes.Buffer
+	if err := png.Encode(&still, img); err != nil {
+		t.Fatal(err)
+	}
+	data := still.Bytes()
+	var buf bytes.Buffer
+	buf.WriteString(pngHeader)
+	for i := 8; i+12 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		name := string(data[i+4 : i+8])
+		if name == "IDAT" && !bytes.Contains(buf.Bytes(), []byte("acTL")) {
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:], 1)
+			writePNGChunk(&buf, "acTL", actl)
+			fctl := make([]byte, 26)
+			binary.BigEndian.PutUint32(fctl[4:], 2)
+			binary.BigEndian.PutUint32(fctl[8:], 2)
+			binary.BigEndian.PutUint16(fctl[20:], num)
+			binary.BigEndian.PutUint16(fctl[22:], den)
+			writePNGChunk(&buf, "fcTL", fctl)
+		}
+		writePNGChunk(&buf, name, data[i+8:i+8+n])
+		i += 12 + n
+	}
+	return buf.Bytes()
+}
+
+func TestAnimationAPNGDelay(t *testing.T) {
+	wsd := newTestDisplay(t, 8, 8, 32)
+	for _, tt := range []struct {
+		num, den uint16
+		want     time.Duration
+	}{
+		{0, 100, defaultFrameDelay},
+		{0, 0, defaultFrameDelay},
+		{1, 0, defaultFrameDelay},
+		{1, 200, defaultFrameDelay},
+		{11, 1000, 11 * time.Millisecond},
+		{25, 100, 250 * time.Millisecond},
+	} {
+		a, err := wsd.LoadAPNG(bytes.NewReader(testAPNG(t, tt.num, tt.den)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, d := a.Frame(0); d != tt.want {
+			t.Errorf("APNG delay %d/%d: %v, want %v", tt.num, tt.den, d, tt.want)
+		}
+	}
+}
+
+func TestAnimationBrokenChunk(t *testing.T) {
+	wsd := newTestDisplay(t, 8, 8, 32)
+	data := testAPNG(t, 1, 10)
+	// IHDR with the length near the max of uint32
+	binary.BigEndian.PutUint32(data[8:], 0x7ffffff0)
+	if _, err := wsd.LoadAPNG(bytes.NewReader(data)); err == nil {
+		t.Error("no error for the broken chunk")
+	}
+	binary.BigEndian.PutUint32(data[8:], 0xfffffff0)
+	if _, err := wsd.LoadAPNG(bytes.NewReader(data)); err == nil {
+		t.Error("no error for the broken chunk")
+	}
+}
+
+// Offset of the data of the first chunk name in the PNG stream
+func chunkData(t *testing.T, data []byte, name string) int {
+	i := bytes.Index(data, []byte(name))
+	if i < 0 {
+		t.Fatalf("no %s chunk", name)
+	}
+	return i + 4
+}
+
+func TestAnimationBrokenSize(t *testing.T) {
+	wsd := newTestDisplay(t, 8, 8, 32)
+	for _, size := range [][2]uint32{
+		{0x7fffffff, 0x7fffffff},
+		{0xffffffff, 2},
+		{0, 2},
+		{animMaxSize + 1, 1},
+		{animMaxSize, animMaxSize},
+	} {
+		data := testAPNG(t, 1, 10)
+		i := chunkData(t, data, "IHDR")
+		binary.BigEndian.PutUint32(data[i:], size[0])
+		binary.BigEndian.PutUint32(data[i+4:], size[1])
+		if _, err := wsd.LoadAPNG(bytes.NewReader(data)); err == nil {
+			t.Errorf("no error for IHDR %dx%d", size[0], size[1])
+		}
+	}
+
+	// frame rect x, y, width, height out of the 2x2 canvas
+	for _, rect := range [][4]uint32{
+		{0, 0, 3, 2},
+		{1, 0, 2, 2},
+		{0, 0, 0, 2},
+		{0xffffffff, 0, 2, 2},
+		{0, 0, 0xffffffff, 0xffffffff},
+	} {
+		data := testAPNG(t, 1, 10)
+		i := chunkData(t, data, "fcTL")
+		binary.BigEndian.PutUint32(data[i+4:], rect[2])
+		binary.BigEndian.PutUint32(data[i+8:], rect[3])
+		binary.BigEndian.PutUint32(data[i+12:], rect[0])
+		binary.BigEndian.PutUint32(data[i+16:], rect[1])
+		if _, err := wsd.LoadAPNG(bytes.NewReader(data)); err == nil {
+			t.Errorf("no error for fcTL %v", rect)
+		}
+	}
+}
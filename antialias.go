@@ -0,0 +1,172 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Anti-aliased drawing operations (Xiaolin Wu's algorithm)
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Blend color c to the pixel at (px, py) with coverage a (0-255)
+func (wsd *WsDisplay) blendPixel(px int, py int, c color.RGBA, a uint32) {
+	if a == 0 || !wsd.inClip(px, py) {
+		return
+	}
+	off := wsd.pixelOffset(px, py)
+	b := wsd.addr[off : off+wsd.bytesPerPixel()]
+	rgbmask := wsd.GetRGBmask()
+	if a < 255 {
+		d, ok := bytesColor(b, rgbmask, wsd.order)
+		if !ok {
+			return
+		}
+		c = color.RGBA{
+			R: uint8((uint32(c.R)*a + uint32(d.R)*(255-a)) / 255),
+			G: uint8((uint32(c.G)*a + uint32(d.G)*(255-a)) / 255),
+			B: uint8((uint32(c.B)*a + uint32(d.B)*(255-a)) / 255),
+			A: 255,
+		}
+	}
+	setBytesColor(b, c, rgbmask, wsd.order)
+}
+
+// Convert coverage 0.0-1.0 to 0-255
+func coverage(f float64) uint32 {
+	if f <= 0 {
+		return 0
+	}
+	if f >= 1 {
+		return 255
+	}
+	return uint32(f*255 + 0.5)
+}
+
+// Draw anti-aliased line from p0 to p1
+func (wsd *WsDisplay) DrawLineAA(p0 image.Point, p1 image.Point, p PIXEL) {
+	c := wsd.pixelColor(p)
+
+	x0, y0 := p0.X, p0.Y
+	x1, y1 := p1.X, p1.Y
+	steep := abs(y1-y0) > abs(x1-x0)
+	if steep {
+		x0, y0 = y0, x0
+		x1, y1 = y1, x1
+	}
+	if x0 > x1 {
+		x0, x1 = x1, x0
+		y0, y1 = y1, y0
+	}
+	plot := func(x int, y int, f float64) {
+		if steep {
+			x, y = y, x
+		}
+		wsd.blendPixel(x, y, c, coverage(f))
+	}
+
+	gradient := 1.0
+	if x1 != x0 {
+		gradient = float64(y1-y0) / float64(x1-x0)
+	}
+	intery := float64(y0)
+	for x := x0; x <= x1; x++ {
+		iy := math.Floor(intery)
+		f := intery - iy
+		plot(x, int(iy), 1-f)
+		plot(x, int(iy)+1, f)
+		intery += gradient
+	}
+}
+
+// Draw anti-aliased circle, center (px, py) and radius r
+func (wsd *WsDisplay) DrawCircleAA(px int, py int, r int, p PIXEL) {
+	wsd.DrawEllipseAA(px, py, r, r, p)
+}
+
+// Draw anti-aliased ellipse, center (px, py) and radius (rx, ry)
+func (wsd *WsDisplay) DrawEllipseAA(px int, py int, rx int, ry int, p PIXEL) {
+	if rx < 0 || ry < 0 {
+		return
+	}
+	c := wsd.pixelColor(p)
+
+	// plot 4 points by symmetry, without overlapping on the axes
+	plot4 := func(dx int, dy int, a uint32) {
+		wsd.blendPixel(px+dx, py+dy, c, a)
+		if dx != 0 {
+			wsd.blendPixel(px-dx, py+dy, c, a)
+		}
+		if dy != 0 {
+			wsd.blendPixel(px+dx, py-dy, c, a)
+			if dx != 0 {
+				wsd.blendPixel(px-dx, py-dy, c, a)
+			}
+		}
+	}
+
+	a2 := float64(rx) * float64(rx)
+	b2 := float64(ry) * float64(ry)
+	if rx == 0 || ry == 0 {
+		wsd.DrawLineAA(image.Pt(px-rx, py-ry), image.Pt(px+rx, py+ry), p)
+		return
+	}
+
+	// the point where the slope of the ellipse is -1, the x major
+	// part plots the columns up to xt and the y major part plots the
+	// columns after it, so no pixel is blended twice.
+	xt := int(math.Floor(a2 / math.Sqrt(a2+b2)))
+
+	// x major part
+	for x := 0; x <= xt; x++ {
+		y := float64(ry) * math.Sqrt(1-float64(x*x)/a2)
+		iy := math.Floor(y)
+		f := y - iy
+		plot4(x, int(iy), coverage(1-f))
+		plot4(x, int(iy)+1, coverage(f))
+	}
+	// y major part
+	for y := 0; y <= ry; y++ {
+		x := float64(rx) * math.Sqrt(1-float64(y*y)/b2)
+		ix := math.Floor(x)
+		f := x - ix
+		if int(ix)+1 <= xt {
+			break
+		}
+		if int(ix) > xt {
+			plot4(int(ix), y, coverage(1-f))
+		}
+		plot4(int(ix)+1, y, coverage(f))
+	}
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Blitter with the wsdisplay blit ioctl and software fallback
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"syscall"
+	"unsafe"
+)
+
+// Blit operations for FBDOBLIT
+//   from WSFB_BLIT_* in dev/wscons/wsconsio.h
+const (
+	FBBLIT_FILL  = 1 // fill the area with the pen
+	FBBLIT_COPY  = 2 // copy the area
+	FBBLIT_TRANS = 3 // copy the area, the pixels same as the pen are skipped
+)
+
+// struct wsdisplayio_blit
+type fbBlit struct {
+	serial uint32
+	op     uint32
+	srcx   uint32
+	srcy   uint32
+	dstx   uint32
+	dsty   uint32
+	width  uint32
+	height uint32
+	pen    uint32
+}
+
+// Blitter copies and fills the areas of the screen, by the hardware if
+// the driver supports it. The clip region and the rotation of the
+// display are applied. The hardware operations may run asynchronously,
+// call Wait before drawing by other operations.
+type Blitter interface {
+	Copy(src image.Rectangle, dst image.Point) error
+	Fill(rect image.Rectangle, p PIXEL) error
+	Wait() error
+	Accelerated() bool
+}
+
+// Create the blitter for the display, the hardware blitter is used if
+// the driver supports the blit ioctl, otherwise the software one.
+func (wsd *WsDisplay) NewBlitter() Blitter {
+	if wsd.HasHardwareBlit() {
+		return &hwBlitter{wsd: wsd}
+	}
+	return wsd.NewSoftBlitter()
+}
+
+// Create the software blitter, it works for in-memory display too.
+func (wsd *WsDisplay) NewSoftBlitter() Blitter {
+	return &softBlitter{wsd: wsd}
+}
+
+// Check the driver supports the blit ioctl by the fill of zero size
+func (wsd *WsDisplay) HasHardwareBlit() bool {
+	if wsd.mem || wsd.fd < 0 {
+		return false
+	}
+	b := fbBlit{op: FBBLIT_FILL}
+	if wsd.blitIoctl(FBDOBLIT, &b) != nil {
+		return false
+	}
+	return wsd.blitIoctl(FBWAITBLIT, &b) == nil
+}
+
+func (wsd *WsDisplay) blitIoctl(req uintptr, b *fbBlit) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(wsd.fd),
+		req, uintptr(unsafe.Pointer(b)))
+	if errno == 0 {
+		return nil
+	}
+	return errno
+}
+
+type softBlitter struct {
+	wsd *WsDisplay
+}
+
+func (b *softBlitter) Copy(src image.Rectangle, dst image.Point) error {
+	b.wsd.CopyArea(src, dst)
+	return nil
+}
+
+func (b *softBlitter) Fill(rect image.Rectangle, p PIXEL) error {
+	b.wsd.FillBox(rect, p)
+	return nil
+}
+
+func (b *softBlitter) Wait() error {
+	return nil
+}
+
+func (b *softBlitter) Accelerated() bool {
+	return false
+}
+
+type hwBlitter struct {
+	wsd    *WsDisplay
+	serial uint32 // serial of the last operation
+	soft   softBlitter
+}
+
+func (b *hwBlitter) do(blit *fbBlit) error {
+	if err := b.wsd.blitIoctl(FBDOBLIT, blit); err != nil {
+		return err
+	}
+	b.serial = blit.serial
+	return nil
+}
+
+func (b *hwBlitter) Copy(src image.Rectangle, dst image.Point) error {
+	src = src.Canon()
+	delta := dst.Sub(src.Min)
+	d := src.Intersect(b.wsd.screenRect()).Add(delta)
+	if d.Empty() || delta == (image.Point{}) {
+		return nil
+	}
+	clips := b.wsd.clipRects()
+	if len(clips) > 1 && d.Overlaps(src) {
+		// the order of the operations matters, do it by software
+		if err := b.Wait(); err != nil {
+			return err
+		}
+		return b.soft.Copy(src, dst)
+	}
+	for _, c := range clips {
+		r := d.Intersect(c)
+		if r.Empty() {
+			continue
+		}
+		pd := b.wsd.physicalRect(r)
+		ps := b.wsd.physicalRect(r.Sub(delta))
+		blit := fbBlit{
+			op:     FBBLIT_COPY,
+			srcx:   uint32(ps.Min.X),
+			srcy:   uint32(ps.Min.Y),
+			dstx:   uint32(pd.Min.X),
+			dsty:   uint32(pd.Min.Y),
+			width:  uint32(pd.Dx()),
+			height: uint32(pd.Dy()),
+		}
+		if err := b.do(&blit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *hwBlitter) Fill(rect image.Rectangle, p PIXEL) error {
+	pen, ok := penValue(p, b.wsd.GetByteOrder())
+	if !ok || len(pixelBytes(p)) != b.wsd.bytesPerPixel() {
+		// no pen value for the pixel
+		if err := b.Wait(); err != nil {
+			return err
+		}
+		return b.soft.Fill(rect, p)
+	}
+	for _, c := range b.wsd.clipRects() {
+		r := rect.Canon().Intersect(c)
+		if r.Empty() {
+			continue
+		}
+		pr := b.wsd.physicalRect(r)
+		blit := fbBlit{
+			op:     FBBLIT_FILL,
+			dstx:   uint32(pr.Min.X),
+			dsty:   uint32(pr.Min.Y),
+			width:  uint32(pr.Dx()),
+			height: uint32(pr.Dy()),
+			pen:    pen,
+		}
+		if err := b.do(&blit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Wait for the last operation
+func (b *hwBlitter) Wait() error {
+	if b.serial == 0 {
+		return nil
+	}
+	blit := fbBlit{serial: b.serial}
+	err := b.wsd.blitIoctl(FBWAITBLIT, &blit)
+	b.serial = 0
+	return err
+}
+
+func (b *hwBlitter) Accelerated() bool {
+	return true
+}
+
+// Raw pixel value for the pen of the blit, the bytes of p are in the
+// byte order of the framebuffer. 24bit pixel is not supported.
+func penValue(p PIXEL, order ByteOrder) (uint32, bool) {
+	switch p.(type) {
+	case *PIXEL32, *PIXEL16, *PIXEL8:
+		return getPixelValue(pixelBytes(p), order), true
+	}
+	return 0, false
+}
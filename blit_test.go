@@ -0,0 +1,58 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for Blitter
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSoftBlitter(t *testing.T) {
+	wsd := newTestDisplay(t, 16, 16, 32)
+	b := wsd.NewBlitter()
+	if b.Accelerated() {
+		t.Fatal("memory display has the hardware blitter")
+	}
+	red := wsd.NewPixel(color.RGBA{255, 0, 0, 255})
+	if err := b.Fill(image.Rect(2, 2, 6, 6), red); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Copy(image.Rect(2, 2, 6, 6), image.Pt(10, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countDrawn(t, wsd, image.Rect(2, 2, 14, 14)); n != 32 {
+		t.Errorf("%d pixels drawn, want 32", n)
+	}
+	if c := wsd.pixelColor(wsd.GetPixel(13, 13)); c != wsd.pixelColor(red) {
+		t.Errorf("copied pixel is %v", c)
+	}
+}
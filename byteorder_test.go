@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for the byte order of the pixels
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var orderColor = color.RGBA{0x12, 0x34, 0x56, 255}
+
+// Raw value of orderColor and its bytes in each order
+var orderCases = []struct {
+	depth int
+	value uint32
+	le    []byte
+	be    []byte
+}{
+	{32, 0x123456, []byte{0x56, 0x34, 0x12, 0x00}, []byte{0x00, 0x12, 0x34, 0x56}},
+	{24, 0x123456, []byte{0x56, 0x34, 0x12}, []byte{0x12, 0x34, 0x56}},
+	// R:G:B = 0x02:0x0d:0x0a in 5:6:5
+	{16, 0x11aa, []byte{0xaa, 0x11}, []byte{0x11, 0xaa}},
+}
+
+// Bytes of orderColor in the order
+func orderBytes(le []byte, be []byte, order ByteOrder) []byte {
+	if order.resolve() == BigEndian {
+		return be
+	}
+	return le
+}
+
+func orderDisplay(t *testing.T, w int, h int, depth int, order ByteOrder) *WsDisplay {
+	wsd := newTestDisplay(t, w, h, depth)
+	wsd.SetByteOrder(order)
+	return wsd
+}
+
+func TestPixelByteOrder(t *testing.T) {
+	for _, tc := range orderCases {
+		for _, order := range []ByteOrder{LittleEndian, BigEndian} {
+			want := orderBytes(tc.le, tc.be, order)
+			m := NewColorModel(tc.depth, testRGBmask(tc.depth), order)
+			p := m.NewPixel(orderColor)
+			if b := pixelBytes(p); !bytes.Equal(b, want) {
+				t.Errorf("depth %d order %d: encoded % x, want % x", tc.depth, order, b, want)
+			}
+			if v := getPixelValue(want, order); v != tc.value {
+				t.Errorf("depth %d order %d: value %#x, want %#x", tc.depth, order, v, tc.value)
+			}
+			// decoding gives the same color in both orders
+			got := pixelColor(p, m.Mask, m.Order)
+			le := NewColorModel(tc.depth, testRGBmask(tc.depth), LittleEndian)
+			if ref := pixelColor(le.NewPixel(orderColor), le.Mask, le.Order); got != ref {
+				t.Errorf("depth %d order %d: decoded %v, want %v", tc.depth, order, got, ref)
+			}
+			if tc.depth != 16 && got != orderColor {
+				t.Errorf("depth %d order %d: decoded %v, want %v", tc.depth, order, got, orderColor)
+			}
+		}
+
+		// SetColor of PIXEL keeps the CPU order
+		p := NewColorModel(tc.depth, testRGBmask(tc.depth), HostOrder).NewPixel(orderColor)
+		p.SetColor(orderColor, testRGBmask(tc.depth))
+		if b, want := pixelBytes(p), orderBytes(tc.le, tc.be, HostOrder); !bytes.Equal(b, want) {
+			t.Errorf("depth %d SetColor: encoded % x, want % x", tc.depth, b, want)
+		}
+	}
+}
+
+func TestDisplayByteOrder(t *testing.T) {
+	for _, tc := range orderCases {
+		wsd := orderDisplay(t, 4, 4, tc.depth, BigEndian)
+		if o := wsd.GetByteOrder(); o != BigEndian {
+			t.Errorf("depth %d: order %d, want BigEndian", tc.depth, o)
+		}
+		wsd.SetPixel(1, 0, wsd.NewPixel(orderColor))
+		n := len(tc.be)
+		if b := wsd.GetBuffer()[n : 2*n]; !bytes.Equal(b, tc.be) {
+			t.Errorf("depth %d big endian: buffer % x, want % x", tc.depth, b, tc.be)
+		}
+
+		// the same bytes read in the other order
+		wsd.SetByteOrder(LittleEndian)
+		if o := wsd.GetByteOrder(); o != LittleEndian {
+			t.Errorf("depth %d: order %d, want LittleEndian", tc.depth, o)
+		}
+		wsd.SetPixel(2, 0, wsd.NewPixel(orderColor))
+		if b := wsd.GetBuffer()[2*n : 3*n]; !bytes.Equal(b, tc.le) {
+			t.Errorf("depth %d little endian: buffer % x, want % x", tc.depth, b, tc.le)
+		}
+		if a, b := wsd.pixelColor(wsd.GetPixel(1, 0)), wsd.pixelColor(wsd.GetPixel(2, 0)); a == b {
+			t.Errorf("depth %d: both orders decode to %v", tc.depth, a)
+		}
+	}
+
+	// HostOrder is resolved
+	wsd := newTestDisplay(t, 4, 4, 32)
+	if o := wsd.GetByteOrder(); o != HostOrder.resolve() || o == HostOrder {
+		t.Errorf("host order is %d", o)
+	}
+}
+
+func TestStoreImageByteOrder(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, orderColor)
+	img.Set(1, 0, orderColor)
+	for _, tc := range orderCases {
+		for _, order := range []ByteOrder{LittleEndian, BigEndian} {
+			wsd := orderDisplay(t, 4, 4, tc.depth, order)
+			p, err := wsd.NewScaledPixelArray(img, 2, 1, Nearest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := orderBytes(tc.le, tc.be, order)
+			b, _ := pixelArrayBytes(p)
+			if !bytes.Equal(b, append(append([]byte{}, want...), want...)) {
+				t.Errorf("depth %d order %d: stored % x, want % x", tc.depth, order, b, want)
+			}
+		}
+	}
+}
+
+func TestPixelFileByteOrder(t *testing.T) {
+	for _, tc := range orderCases {
+		be := orderDisplay(t, 8, 8, tc.depth, BigEndian)
+		le := orderDisplay(t, 8, 8, tc.depth, LittleEndian)
+		_, data := testPixelFile(t, be, false)
+		if _, err := be.LoadPixelArray(bytes.NewReader(data)); err != nil {
+			t.Errorf("depth %d: %v", tc.depth, err)
+		}
+		if _, err := le.LoadPixelArray(bytes.NewReader(data)); err == nil {
+			t.Errorf("depth %d: no error for the different byte order", tc.depth)
+		}
+	}
+}
+
+func TestPenValue(t *testing.T) {
+	for _, tc := range orderCases {
+		for _, order := range []ByteOrder{LittleEndian, BigEndian} {
+			p := NewColorModel(tc.depth, testRGBmask(tc.depth), order).NewPixel(orderColor)
+			v, ok := penValue(p, order)
+			if tc.depth == 24 {
+				if ok {
+					t.Errorf("pen value for 24bit pixel")
+				}
+				continue
+			}
+			if !ok || v != tc.value {
+				t.Errorf("depth %d order %d: pen %#x, want %#x", tc.depth, order, v, tc.value)
+			}
+		}
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Canvas; drawing context with the origin, clip, colors and line style
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+)
+
+// Canvas draws to the WsDisplay (or the in-memory display made by
+// NewMemDisplay) in the local coordinates. The origin (0, 0) of the canvas
+// is at the origin point of the screen, and the drawing is clipped by
+// the bounds of the canvas.
+// Canvas is a small value, so sub canvases for nested widgets are cheap.
+type Canvas struct {
+	wsd    *WsDisplay
+	origin image.Point     // origin in the screen coordinates
+	clip   image.Rectangle // clip in the screen coordinates
+	fg     PIXEL           // foreground pixel for drawing and filling
+	bg     PIXEL           // background pixel for Clear
+	style  LineStyle       // line style for Stroke* operations
+	font   Font            // font for DrawText
+	matrix Affine          // transform for path based operations
+}
+
+// Clip of the root canvas, it's limited by the screen at drawing time,
+// so the canvas follows SetRotation of the display.
+var canvasNoClip = image.Rect(-1<<30, -1<<30, 1<<30, 1<<30)
+
+// Create new canvas of the whole screen, the foreground is white,
+// the background is black and the font is DefaultFont.
+func NewCanvas(wsd *WsDisplay) *Canvas {
+	return &Canvas{
+		wsd:    wsd,
+		origin: image.Pt(0, 0),
+		clip:   canvasNoClip,
+		fg:     wsd.NewPixel(color.RGBA{255, 255, 255, 255}),
+		bg:     wsd.NewPixel(color.RGBA{0, 0, 0, 255}),
+		style:  LineStyle{Width: 1},
+		font:   DefaultFont,
+		matrix: IdentityAffine(),
+	}
+}
+
+// Create sub canvas of the area r in the local coordinates.
+// The origin of the sub canvas is r.Min, and it's clipped by r and
+// the bounds of c. The colors and the style are inherited.
+func (c *Canvas) Sub(r image.Rectangle) *Canvas {
+	sub := *c
+	r = r.Canon().Add(c.origin)
+	sub.origin = r.Min
+	sub.clip = c.clip.Intersect(r)
+	return &sub
+}
+
+// Get the display of the canvas
+func (c *Canvas) GetDisplay() *WsDisplay {
+	return c.wsd
+}
+
+// Get the origin in the screen coordinates
+func (c *Canvas) GetOrigin() image.Point {
+	return c.origin
+}
+
+// Drawable area in the screen coordinates, the clip in the current screen
+func (c *Canvas) area() image.Rectangle {
+	return c.clip.Intersect(c.wsd.screenRect())
+}
+
+// Get the drawable area in the local coordinates
+func (c *Canvas) Bounds() image.Rectangle {
+	return c.area().Sub(c.origin)
+}
+
+// Get the width of the drawable area
+func (c *Canvas) GetWidth() int {
+	return c.area().Dx()
+}
+
+// Get the height of the drawable area
+func (c *Canvas) GetHeight() int {
+	return c.area().Dy()
+}
+
+// Move the origin by (dx, dy), the clip is not changed.
+func (c *Canvas) Translate(dx int, dy int) {
+	c.origin = c.origin.Add(image.Pt(dx, dy))
+}
+
+// Restrict the clip to the area r in the local coordinates
+func (c *Canvas) Clip(r image.Rectangle) {
+	c.clip = c.clip.Intersect(r.Canon().Add(c.origin))
+}
+
+func (c *Canvas) SetForeground(p PIXEL) {
+	c.fg = p
+}
+
+func (c *Canvas) GetForeground() PIXEL {
+	return c.fg
+}
+
+func (c *Canvas) SetBackground(p PIXEL) {
+	c.bg = p
+}
+
+func (c *Canvas) GetBackground() PIXEL {
+	return c.bg
+}
+
+// Set the foreground by color.Color
+func (c *Canvas) SetForegroundColor(col color.Color) {
+	c.fg = c.wsd.NewPixel(col)
+}
+
+// Set the background by color.Color
+func (c *Canvas) SetBackgroundColor(col color.Color) {
+	c.bg = c.wsd.NewPixel(col)
+}
+
+func (c *Canvas) SetLineStyle(style LineStyle) {
+	c.style = style
+}
+
+func (c *Canvas) GetLineStyle() LineStyle {
+	return c.style
+}
+
+func (c *Canvas) SetFont(f Font) {
+	c.font = f
+}
+
+func (c *Canvas) GetFont() Font {
+	return c.font
+}
+
+// Set the transform for the path based operations; Fill/Stroke of
+// polygons and paths, and PutPixelArrayTransform/DrawImageTransform.
+// It's applied in the local coordinates, before the origin.
+func (c *Canvas) SetTransform(m Affine) {
+	c.matrix = m
+}
+
+func (c *Canvas) GetTransform() Affine {
+	return c.matrix
+}
+
+// Apply m in the current transform, m is applied at first
+func (c *Canvas) Transform(m Affine) {
+	c.matrix = c.matrix.Mul(m)
+}
+
+// Transform from the local coordinates to the screen
+func (c *Canvas) screenMatrix() Affine {
+	return TranslateAffine(float64(c.origin.X), float64(c.origin.Y)).Mul(c.matrix)
+}
+
+// Convert the points to the screen in sub-pixel coordinates
+func (c *Canvas) toScreenF(pts []image.Point) []fpoint {
+	return c.screenMatrix().applyPolygons([][]fpoint{toFpoints(pts)})[0]
+}
+
+// Stroke the polyline in the local coordinates
+func (c *Canvas) stroke(pts []image.Point, closed bool) {
+	c.begin()
+	defer c.end()
+	m := c.screenMatrix()
+	c.wsd.fillPolygons(strokePolygons(c.toScreenF(pts), closed, m.applyStyle(c.style)), NonZero, c.fg)
+}
+
+// Set the clip of the canvas to the display, call end() after drawing.
+func (c *Canvas) begin() {
+	c.wsd.PushClip(c.area())
+}
+
+func (c *Canvas) end() {
+	c.wsd.PopClip()
+}
+
+// Convert the points to the screen coordinates
+func (c *Canvas) toScreen(pts []image.Point) []image.Point {
+	q := make([]image.Point, len(pts))
+	for i, pt := range pts {
+		q[i] = pt.Add(c.origin)
+	}
+	return q
+}
+
+// Fill the canvas with the background
+func (c *Canvas) Clear() {
+	c.begin()
+	defer c.end()
+	c.wsd.FillBox(c.area(), c.bg)
+}
+
+func (c *Canvas) SetPixel(x int, y int) {
+	c.begin()
+	defer c.end()
+	c.wsd.SetPixel(x+c.origin.X, y+c.origin.Y, c.fg)
+}
+
+// Get the pixel data at (x, y), nil if it's out of the canvas
+func (c *Canvas) GetPixel(x int, y int) PIXEL {
+	pt := image.Pt(x, y).Add(c.origin)
+	if !pt.In(c.area()) {
+		return nil
+	}
+	return c.wsd.GetPixel(pt.X, pt.Y)
+}
+
+func (c *Canvas) DrawLine(p0 image.Point, p1 image.Point) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawLine(p0.Add(c.origin), p1.Add(c.origin), c.fg)
+}
+
+func (c *Canvas) DrawLineAA(p0 image.Point, p1 image.Point) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawLineAA(p0.Add(c.origin), p1.Add(c.origin), c.fg)
+}
+
+func (c *Canvas) DrawPolyline(pts []image.Point) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawPolyline(c.toScreen(pts), c.fg)
+}
+
+func (c *Canvas) DrawBox(rect image.Rectangle) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawBox(rect.Add(c.origin), c.fg)
+}
+
+func (c *Canvas) FillBox(rect image.Rectangle) {
+	c.begin()
+	defer c.end()
+	c.wsd.FillBox(rect.Add(c.origin), c.fg)
+}
+
+func (c *Canvas) DrawCircle(x int, y int, r int) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawCircle(x+c.origin.X, y+c.origin.Y, r, c.fg)
+}
+
+func (c *Canvas) FillCircle(x int, y int, r int) {
+	c.begin()
+	defer c.end()
+	c.wsd.FillCircle(x+c.origin.X, y+c.origin.Y, r, c.fg)
+}
+
+func (c *Canvas) DrawCircleAA(x int, y int, r int) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawCircleAA(x+c.origin.X, y+c.origin.Y, r, c.fg)
+}
+
+func (c *Canvas) DrawEllipse(x int, y int, rx int, ry int) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawEllipse(x+c.origin.X, y+c.origin.Y, rx, ry, c.fg)
+}
+
+func (c *Canvas) FillEllipse(x int, y int, rx int, ry int) {
+	c.begin()
+	defer c.end()
+	c.wsd.FillEllipse(x+c.origin.X, y+c.origin.Y, rx, ry, c.fg)
+}
+
+func (c *Canvas) DrawEllipseAA(x int, y int, rx int, ry int) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawEllipseAA(x+c.origin.X, y+c.origin.Y, rx, ry, c.fg)
+}
+
+func (c *Canvas) DrawArc(x int, y int, rx int, ry int, start float64, sweep float64) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawArc(x+c.origin.X, y+c.origin.Y, rx, ry, start, sweep, c.fg)
+}
+
+func (c *Canvas) FillPie(x int, y int, rx int, ry int, start float64, sweep float64) {
+	c.begin()
+	defer c.end()
+	c.wsd.FillPie(x+c.origin.X, y+c.origin.Y, rx, ry, start, sweep, c.fg)
+}
+
+func (c *Canvas) DrawRoundRect(rect image.Rectangle, r int) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawRoundRect(rect.Add(c.origin), r, c.fg)
+}
+
+func (c *Canvas) FillRoundRect(rect image.Rectangle, r int) {
+	c.begin()
+	defer c.end()
+	c.wsd.FillRoundRect(rect.Add(c.origin), r, c.fg)
+}
+
+func (c *Canvas) FillPolygon(pts []image.Point, rule FillRule) {
+	c.begin()
+	defer c.end()
+	c.wsd.fillPolygons([][]fpoint{c.toScreenF(pts)}, rule, c.fg)
+}
+
+func (c *Canvas) FillPolygonAA(pts []image.Point, rule FillRule) {
+	c.begin()
+	defer c.end()
+	c.wsd.fillPolygonsAA([][]fpoint{c.toScreenF(pts)}, rule, c.fg)
+}
+
+func (c *Canvas) FillPath(path *Path, rule FillRule) {
+	c.begin()
+	defer c.end()
+	c.wsd.FillPath(path.Transform(c.screenMatrix()), rule, c.fg)
+}
+
+func (c *Canvas) FillPathAA(path *Path, rule FillRule) {
+	c.begin()
+	defer c.end()
+	c.wsd.FillPathAA(path.Transform(c.screenMatrix()), rule, c.fg)
+}
+
+func (c *Canvas) StrokeLine(p0 image.Point, p1 image.Point) {
+	c.stroke([]image.Point{p0, p1}, false)
+}
+
+func (c *Canvas) StrokePolyline(pts []image.Point) {
+	c.stroke(pts, false)
+}
+
+func (c *Canvas) StrokePolygon(pts []image.Point) {
+	c.stroke(pts, true)
+}
+
+func (c *Canvas) StrokeBox(rect image.Rectangle) {
+	rect = rect.Canon()
+	if rect.Empty() {
+		return
+	}
+	c.stroke([]image.Point{
+		rect.Min,
+		image.Pt(rect.Max.X-1, rect.Min.Y),
+		image.Pt(rect.Max.X-1, rect.Max.Y-1),
+		image.Pt(rect.Min.X, rect.Max.Y-1),
+	}, true)
+}
+
+func (c *Canvas) StrokeCircle(x int, y int, r int) {
+	c.begin()
+	defer c.end()
+	m := c.screenMatrix()
+	poly := m.applyPolygons([][]fpoint{circlePolygon(pixelCenter(x, y), float64(r))})[0]
+	c.wsd.fillPolygons(strokePolygons(poly, true, m.applyStyle(c.style)), NonZero, c.fg)
+}
+
+func (c *Canvas) StrokePath(path *Path) {
+	c.begin()
+	defer c.end()
+	m := c.screenMatrix()
+	c.wsd.StrokePath(path.Transform(m), m.applyStyle(c.style), c.fg)
+}
+
+// Draw the text at (x, y) in the local coordinates with the font
+func (c *Canvas) DrawText(x int, y int, s string) {
+	c.begin()
+	defer c.end()
+	c.wsd.DrawText(x+c.origin.X, y+c.origin.Y, s, c.font.Scale, c.fg)
+}
+
+// Draw the image at (x, y) in the local coordinates
+func (c *Canvas) PutPixelArray(x int, y int, p PIXELARRAY) error {
+	c.begin()
+	defer c.end()
+	return c.wsd.PutPixelArray(x+c.origin.X, y+c.origin.Y, p)
+}
+
+// Draw the src area of the image at dst in the local coordinates
+func (c *Canvas) PutPixelArrayRect(dst image.Point, p PIXELARRAY, src image.Rectangle) error {
+	c.begin()
+	defer c.end()
+	return c.wsd.PutPixelArrayRect(dst.Add(c.origin), p, src)
+}
+
+// Draw the image with the transform of the canvas
+func (c *Canvas) PutPixelArrayTransform(p PIXELARRAY, interp Interpolation) error {
+	c.begin()
+	defer c.end()
+	return c.wsd.PutPixelArrayTransform(p, c.screenMatrix(), interp)
+}
+
+// Draw the image with the transform of the canvas
+func (c *Canvas) DrawImageTransform(img image.Image, interp Interpolation) error {
+	c.begin()
+	defer c.end()
+	return c.wsd.DrawImageTransform(img, c.screenMatrix(), interp)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for Canvas
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCanvasRotation(t *testing.T) {
+	wsd := newTestDisplay(t, 40, 20, 32)
+	cv := NewCanvas(wsd)
+	if got := cv.Bounds(); got != image.Rect(0, 0, 40, 20) {
+		t.Errorf("Bounds %v, want (0,0)-(40,20)", got)
+	}
+	if err := wsd.SetRotation(Rotate90); err != nil {
+		t.Fatal(err)
+	}
+	if got := cv.Bounds(); got != image.Rect(0, 0, 20, 40) {
+		t.Errorf("rotated Bounds %v, want (0,0)-(20,40)", got)
+	}
+	if cv.GetWidth() != 20 || cv.GetHeight() != 40 {
+		t.Errorf("rotated size %dx%d, want 20x40", cv.GetWidth(), cv.GetHeight())
+	}
+	cv.Clear()
+	cv.FillBox(image.Rect(0, 30, 20, 40))
+	if countDrawn(t, wsd, image.Rect(0, 30, 20, 40)) != 200 {
+		t.Error("FillBox on the rotated canvas")
+	}
+}
+
+func TestCanvasSub(t *testing.T) {
+	wsd := newTestDisplay(t, 40, 30, 32)
+	cv := NewCanvas(wsd)
+	sub := cv.Sub(image.Rect(10, 5, 30, 25))
+	if sub.GetOrigin() != image.Pt(10, 5) || sub.Bounds() != image.Rect(0, 0, 20, 20) {
+		t.Errorf("Sub origin %v bounds %v", sub.GetOrigin(), sub.Bounds())
+	}
+	inner := sub.Sub(image.Rect(15, 15, 40, 40))
+	if inner.Bounds() != image.Rect(0, 0, 5, 5) {
+		t.Errorf("nested Sub bounds %v, want (0,0)-(5,5)", inner.Bounds())
+	}
+	inner.FillBox(image.Rect(-100, -100, 100, 100))
+	if n := countDrawn(t, wsd, image.Rect(25, 20, 30, 25)); n != 25 {
+		t.Errorf("nested Sub: %d pixels drawn, want 25", n)
+	}
+	if wsd.clip != nil || len(wsd.clipStack) != 0 {
+		t.Error("canvas leaves the clip region of the display")
+	}
+}
+
+func TestCanvasFont(t *testing.T) {
+	wsd := newTestDisplay(t, 40, 30, 32)
+	cv := NewCanvas(wsd)
+	if cv.GetFont() != DefaultFont {
+		t.Errorf("font %v, want DefaultFont", cv.GetFont())
+	}
+	cv.SetFont(Font{Scale: 2})
+	sub := cv.Sub(image.Rect(4, 4, 40, 30))
+	sub.SetForegroundColor(color.White)
+	sub.DrawText(0, 0, "I")
+	size := sub.GetFont().TextSize("I")
+	if size != image.Pt(2*FontWidth, 2*FontHeight) {
+		t.Errorf("TextSize %v", size)
+	}
+	area := image.Rectangle{Max: size}.Add(image.Pt(4, 4))
+	if countDrawn(t, wsd, area) == 0 {
+		t.Error("nothing is drawn by DrawText")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Clip region of the drawing operations
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+)
+
+// Set the clip region to the rectangle.
+// All drawing operations draw only inside of the clip region.
+func (wsd *WsDisplay) SetClip(r image.Rectangle) {
+	wsd.SetClipRects([]image.Rectangle{r})
+}
+
+// Set the clip region to the union of the rectangles
+func (wsd *WsDisplay) SetClipRects(rs []image.Rectangle) {
+	wsd.clip = disjointRects(rs)
+}
+
+// Remove the clip region, the whole screen is drawable.
+// The saved regions by PushClip are not changed.
+func (wsd *WsDisplay) ResetClip() {
+	wsd.clip = nil
+}
+
+// Get the current clip region as the non-overlapped rectangles,
+// the whole screen if it is not set.
+func (wsd *WsDisplay) GetClip() []image.Rectangle {
+	return wsd.clipRects()
+}
+
+// Save the current clip region, and set the intersection of it and r
+func (wsd *WsDisplay) PushClip(r image.Rectangle) {
+	wsd.clipStack = append(wsd.clipStack, wsd.clip)
+	var rs []image.Rectangle
+	for _, c := range wsd.clipRects() {
+		c = c.Intersect(r)
+		if !c.Empty() {
+			rs = append(rs, c)
+		}
+	}
+	if rs == nil {
+		// nothing is drawable, but it is not same as no clip
+		rs = []image.Rectangle{}
+	}
+	wsd.clip = rs
+}
+
+// Restore the clip region saved by PushClip.
+// It does nothing if no clip region is saved.
+func (wsd *WsDisplay) PopClip() {
+	n := len(wsd.clipStack)
+	if n == 0 {
+		return
+	}
+	wsd.clip = wsd.clipStack[n-1]
+	wsd.clipStack = wsd.clipStack[:n-1]
+}
+
+// Rectangle of the whole screen
+func (wsd *WsDisplay) screenRect() image.Rectangle {
+	return image.Rect(0, 0, wsd.GetWidth(), wsd.GetHeight())
+}
+
+// Effective clip rectangles in the screen
+func (wsd *WsDisplay) clipRects() []image.Rectangle {
+	screen := wsd.screenRect()
+	if wsd.clip == nil {
+		return []image.Rectangle{screen}
+	}
+	rs := make([]image.Rectangle, 0, len(wsd.clip))
+	for _, c := range wsd.clip {
+		c = c.Intersect(screen)
+		if !c.Empty() {
+			rs = append(rs, c)
+		}
+	}
+	return rs
+}
+
+// Bounding box of the clip rectangles in the screen
+func (wsd *WsDisplay) clipBounds() image.Rectangle {
+	var b image.Rectangle
+	for _, c := range wsd.clipRects() {
+		b = b.Union(c)
+	}
+	return b
+}
+
+// Check (px, py) is drawable
+func (wsd *WsDisplay) inClip(px int, py int) bool {
+	if px < 0 || py < 0 || px >= wsd.GetWidth() || py >= wsd.GetHeight() {
+		return false
+	}
+	if wsd.clip == nil {
+		return true
+	}
+	pt := image.Pt(px, py)
+	for _, c := range wsd.clip {
+		if pt.In(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Make non-overlapped rectangles which cover the same area as rs
+func disjointRects(rs []image.Rectangle) []image.Rectangle {
+	res := []image.Rectangle{}
+	for _, r := range rs {
+		r = r.Canon()
+		pieces := []image.Rectangle{r}
+		for _, d := range res {
+			var next []image.Rectangle
+			for _, p := range pieces {
+				next = append(next, subtractRect(p, d)...)
+			}
+			pieces = next
+		}
+		res = append(res, pieces...)
+	}
+	return res
+}
+
+// Split r - d into rectangles
+func subtractRect(r image.Rectangle, d image.Rectangle) []image.Rectangle {
+	if r.Empty() {
+		return nil
+	}
+	i := r.Intersect(d)
+	if i.Empty() {
+		return []image.Rectangle{r}
+	}
+	var res []image.Rectangle
+	if r.Min.Y < i.Min.Y {
+		res = append(res, image.Rect(r.Min.X, r.Min.Y, r.Max.X, i.Min.Y))
+	}
+	if i.Max.Y < r.Max.Y {
+		res = append(res, image.Rect(r.Min.X, i.Max.Y, r.Max.X, r.Max.Y))
+	}
+	if r.Min.X < i.Min.X {
+		res = append(res, image.Rect(r.Min.X, i.Min.Y, i.Min.X, i.Max.Y))
+	}
+	if i.Max.X < r.Max.X {
+		res = append(res, image.Rect(i.Max.X, i.Min.Y, r.Max.X, i.Max.Y))
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for the clip region
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Count the pixels which are not black, and check they are in r
+func countDrawn(t *testing.T, wsd *WsDisplay, r image.Rectangle) int {
+	t.Helper()
+	n := 0
+	for y := 0; y < wsd.GetHeight(); y++ {
+		for x := 0; x < wsd.GetWidth(); x++ {
+			c := wsd.pixelColor(wsd.GetPixel(x, y))
+			if c.R == 0 && c.G == 0 && c.B == 0 {
+				continue
+			}
+			if !image.Pt(x, y).In(r) {
+				t.Errorf("(%d, %d) is drawn out of %v", x, y, r)
+			}
+			n++
+		}
+	}
+	return n
+}
+
+func TestClipPrimitives(t *testing.T) {
+	area := image.Rect(10, 8, 30, 20)
+	draws := map[string]func(wsd *WsDisplay, p PIXEL){
+		"FillBox": func(wsd *WsDisplay, p PIXEL) {
+			wsd.FillBox(image.Rect(0, 0, 40, 30), p)
+		},
+		"DrawLine": func(wsd *WsDisplay, p PIXEL) {
+			wsd.DrawLine(image.Pt(0, 0), image.Pt(39, 29), p)
+		},
+		"FillCircle": func(wsd *WsDisplay, p PIXEL) {
+			wsd.FillCircle(20, 15, 14, p)
+		},
+		"FillPolygonAA": func(wsd *WsDisplay, p PIXEL) {
+			wsd.FillPolygonAA([]image.Point{{0, 0}, {39, 5}, {20, 29}}, NonZero, p)
+		},
+		"DrawText": func(wsd *WsDisplay, p PIXEL) {
+			wsd.DrawText(0, 6, "CLIPPED TEXT", 2, p)
+		},
+		"PutPixelArray": func(wsd *WsDisplay, p PIXEL) {
+			src := newTestDisplay(t, 40, 30, 32)
+			src.FillBox(image.Rect(0, 0, 40, 30), p)
+			pa, err := src.GetPixelArray(image.Rect(0, 0, 40, 30))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wsd.PutPixelArray(0, 0, pa)
+		},
+	}
+	for name, draw := range draws {
+		wsd := newTestDisplay(t, 40, 30, 32)
+		wsd.SetClip(area)
+		draw(wsd, wsd.NewPixel(color.White))
+		if countDrawn(t, wsd, area) == 0 {
+			t.Errorf("%s: nothing is drawn in the clip region", name)
+		}
+	}
+}
+
+func TestClipStack(t *testing.T) {
+	wsd := newTestDisplay(t, 40, 30, 32)
+	outer := image.Rect(5, 5, 35, 25)
+	wsd.SetClip(outer)
+	wsd.PushClip(image.Rect(0, 0, 20, 20))
+	wsd.PushClip(image.Rect(10, 10, 40, 30))
+	wsd.FillBox(wsd.screenRect(), wsd.NewPixel(color.White))
+	if n := countDrawn(t, wsd, image.Rect(10, 10, 20, 20)); n != 100 {
+		t.Errorf("nested clip: %d pixels drawn, want 100", n)
+	}
+	wsd.PopClip()
+	wsd.PopClip()
+	if got := wsd.clipBounds(); got != outer {
+		t.Errorf("after PopClip: clip %v, want %v", got, outer)
+	}
+	// unbalanced PopClip keeps the clip region
+	wsd.PopClip()
+	if got := wsd.clipBounds(); got != outer {
+		t.Errorf("PopClip on empty stack: clip %v, want %v", got, outer)
+	}
+}
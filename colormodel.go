@@ -0,0 +1,200 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Color model of the framebuffer pixel format
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Encoded native pixel with its format, it implements color.Color.
+// Pix holds the raw bytes of the pixel, only the first (Depth+7)/8
+// bytes are used.
+type NativeColor struct {
+	Pix   [4]uint8
+	Depth int
+	Mask  RGBmask
+	Order ByteOrder
+}
+
+// Get the native pixel as PIXEL, nil if the depth is unsupported
+func (c NativeColor) Pixel() PIXEL {
+	n := (c.Depth + 7) / 8
+	if n < 1 || n > len(c.Pix) {
+		return nil
+	}
+	return newPixelFromBytes(c.Pix[:n])
+}
+
+func (c NativeColor) RGBA() (r, g, b, a uint32) {
+	p := c.Pixel()
+	if p == nil {
+		return 0, 0, 0, 0
+	}
+	return pixelColor(p, c.Mask, c.Order).RGBA()
+}
+
+// Color model of the pixel format given by depth, RGBmask and byte
+// order, it converts any color to NativeColor.
+type PixelModel struct {
+	Depth int
+	Mask  RGBmask
+	Order ByteOrder
+}
+
+func NewColorModel(depth int, rgbmask RGBmask, order ByteOrder) PixelModel {
+	return PixelModel{depth, rgbmask, order.resolve()}
+}
+
+// Get the color model of the display
+func (wsd *WsDisplay) ColorModel() PixelModel {
+	return NewColorModel(wsd.GetDepth(), wsd.GetRGBmask(), wsd.GetByteOrder())
+}
+
+func (m PixelModel) Convert(c color.Color) color.Color {
+	if n, ok := c.(NativeColor); ok && n.Depth == m.Depth && n.Mask == m.Mask &&
+		n.Order == m.Order {
+		return n
+	}
+	return m.Decode(m.NewPixel(c))
+}
+
+// Encode the color to the PIXEL, nil if the depth is unsupported
+func (m PixelModel) NewPixel(c color.Color) (p PIXEL) {
+	switch m.Depth {
+	case 32:
+		p = new(PIXEL32)
+	case 24:
+		p = new(PIXEL24)
+	case 16:
+		p = new(PIXEL16)
+	case 8:
+		p = new(PIXEL8)
+	default:
+		return nil
+	}
+	setPixelColor(p, c, m.Mask, m.Order)
+	return
+}
+
+// Get the PIXEL as NativeColor, Decode(NewPixel(c)).Pixel() has the
+// same raw data as NewPixel(c).
+func (m PixelModel) Decode(p PIXEL) color.Color {
+	n := NativeColor{Depth: m.Depth, Mask: m.Mask, Order: m.Order}
+	copy(n.Pix[:], pixelBytes(p))
+	return n
+}
+
+// draw.Image on the display
+
+type displayImage struct {
+	wsd *WsDisplay
+	m   PixelModel
+}
+
+// Get the display as draw.Image, Set draws in the clip region
+func (wsd *WsDisplay) Image() draw.Image {
+	return &displayImage{wsd, wsd.ColorModel()}
+}
+
+func (d *displayImage) ColorModel() color.Model {
+	return d.m
+}
+
+func (d *displayImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, d.wsd.GetWidth(), d.wsd.GetHeight())
+}
+
+func (d *displayImage) At(x int, y int) color.Color {
+	p := d.wsd.GetPixel(x, y)
+	if p == nil {
+		return color.RGBA{}
+	}
+	return d.m.Decode(p)
+}
+
+func (d *displayImage) Set(x int, y int, c color.Color) {
+	if p := d.m.Convert(c).(NativeColor).Pixel(); p != nil {
+		d.wsd.SetPixel(x, y, p)
+	}
+}
+
+// draw.Image on the PIXELARRAY
+
+type pixelArrayImage struct {
+	p PIXELARRAY
+	m PixelModel
+}
+
+// Get the PIXELARRAY as draw.Image. The masked out pixels are transparent
+// for At, and Set makes the pixel unmasked.
+func (wsd *WsDisplay) NewPixelArrayImage(p PIXELARRAY) (draw.Image, error) {
+	if err := wsd.checkPixelArray(p); err != nil {
+		return nil, err
+	}
+	return &pixelArrayImage{p, wsd.ColorModel()}, nil
+}
+
+func (a *pixelArrayImage) ColorModel() color.Model {
+	return a.m
+}
+
+func (a *pixelArrayImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, a.p.GetWidth(), a.p.GetHeight())
+}
+
+// Index of (x, y) in the array, -1 if out of the array
+func (a *pixelArrayImage) index(x int, y int) int {
+	if !(image.Point{x, y}.In(a.Bounds())) {
+		return -1
+	}
+	return x + y*a.p.GetWidth()
+}
+
+func (a *pixelArrayImage) At(x int, y int) color.Color {
+	i := a.index(x, y)
+	if i < 0 || !a.p.GetMasks()[i] {
+		return color.RGBA{}
+	}
+	b, bpp := pixelArrayBytes(a.p)
+	n := NativeColor{Depth: a.m.Depth, Mask: a.m.Mask, Order: a.m.Order}
+	copy(n.Pix[:], b[i*bpp:(i+1)*bpp])
+	return n
+}
+
+func (a *pixelArrayImage) Set(x int, y int, c color.Color) {
+	i := a.index(x, y)
+	if i < 0 {
+		return
+	}
+	n := a.m.Convert(c).(NativeColor)
+	b, bpp := pixelArrayBytes(a.p)
+	copy(b[i*bpp:(i+1)*bpp], n.Pix[:bpp])
+	a.p.GetMasks()[i] = true
+}
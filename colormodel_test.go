@@ -0,0 +1,164 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for the color.Model and draw.Image
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var modelColors = []color.Color{
+	color.Black,
+	color.White,
+	color.RGBA{255, 0, 0, 255},
+	color.RGBA{0, 255, 0, 255},
+	color.RGBA{0, 0, 255, 255},
+	color.RGBA{0x12, 0x34, 0x56, 255},
+	color.NRGBA{0x80, 0x40, 0xc0, 255},
+	color.Gray{0x7f},
+}
+
+// Quantization error allowed for each channel of the depth
+func modelTolerance(depth int) uint32 {
+	switch depth {
+	case 16:
+		return 8 << 8
+	case 8:
+		return 0xffff
+	}
+	return 1 << 8
+}
+
+func absDiff(a uint32, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestColorModel(t *testing.T) {
+	for _, depth := range []int{8, 16, 24, 32} {
+		m := NewColorModel(depth, testRGBmask(depth), HostOrder)
+		tol := modelTolerance(depth)
+		for _, c := range modelColors {
+			n := m.Convert(c)
+			if _, ok := n.(NativeColor); !ok {
+				t.Fatalf("depth %d: Convert returns %T", depth, n)
+			}
+			// Convert is idempotent
+			if again := m.Convert(n); again != n {
+				t.Errorf("depth %d: Convert(Convert(%v)) = %v, want %v", depth, c, again, n)
+			}
+			// Decode(NewPixel(c)) is Convert(c)
+			if d := m.Decode(m.NewPixel(c)); d != n {
+				t.Errorf("depth %d: Decode(NewPixel(%v)) = %v, want %v", depth, c, d, n)
+			}
+			r0, g0, b0, _ := c.RGBA()
+			r1, g1, b1, a1 := n.RGBA()
+			if a1 != 0xffff {
+				t.Errorf("depth %d: %v converted alpha %#x", depth, c, a1)
+			}
+			if depth == 8 {
+				continue
+			}
+			if absDiff(r0, r1) > tol || absDiff(g0, g1) > tol || absDiff(b0, b1) > tol {
+				t.Errorf("depth %d: %v converted to %#x %#x %#x", depth, c, r1, g1, b1)
+			}
+		}
+	}
+	// gray is exact on 8bpp
+	m := NewColorModel(8, RGBmask{}, HostOrder)
+	if r, _, _, _ := m.Convert(color.Gray{0x7f}).RGBA(); r != 0x7f7f {
+		t.Errorf("depth 8: gray converted to %#x", r)
+	}
+	if p := NewColorModel(12, RGBmask{}, HostOrder).NewPixel(color.White); p != nil {
+		t.Errorf("depth 12: NewPixel = %v, want nil", p)
+	}
+}
+
+// Set then At returns the converted color
+func testImageRoundTrip(t *testing.T, name string, img draw.Image) {
+	m := img.ColorModel()
+	b := img.Bounds()
+	for i, c := range modelColors {
+		x, y := b.Min.X+i%b.Dx(), b.Min.Y+i/b.Dx()
+		img.Set(x, y, c)
+		if got, want := img.At(x, y), m.Convert(c); got != want {
+			t.Errorf("%s: At(%d, %d) = %v, want %v", name, x, y, got, want)
+		}
+	}
+}
+
+func TestImageSetAt(t *testing.T) {
+	for _, depth := range []int{16, 24, 32} {
+		wsd := newTestDisplay(t, 8, 8, depth)
+		testImageRoundTrip(t, "display", wsd.Image())
+
+		p, err := wsd.newPixelArraySize(4, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := wsd.NewPixelArrayImage(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := img.At(0, 0); c != (color.RGBA{}) {
+			t.Errorf("depth %d: masked pixel is %v, want transparent", depth, c)
+		}
+		testImageRoundTrip(t, "pixelarray", img)
+		if !p.GetMasks()[0] {
+			t.Errorf("depth %d: Set leaves the pixel masked", depth)
+		}
+	}
+}
+
+func TestImageDraw(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i, c := range modelColors {
+		src.Set(i%4, i/4, c)
+	}
+	for _, depth := range []int{16, 24, 32} {
+		wsd := newTestDisplay(t, 8, 8, depth)
+		dst := wsd.Image()
+		draw.Draw(dst, image.Rect(2, 2, 6, 6), src, image.Point{}, draw.Src)
+		m := wsd.ColorModel()
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				want := m.Convert(color.Black)
+				if (image.Point{x, y}).In(image.Rect(2, 2, 6, 6)) {
+					want = m.Convert(src.At(x-2, y-2))
+				}
+				if got := dst.At(x, y); got != want {
+					t.Errorf("depth %d: (%d, %d) = %v, want %v", depth, x, y, got, want)
+				}
+			}
+		}
+	}
+}
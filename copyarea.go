@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Screen to screen copy and scrolling
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+)
+
+// Copy the src area of the screen to dst. The overlapped areas are
+// copied correctly. The area of src out of the screen is not copied
+// and the destination is clipped by the clip region.
+func (wsd *WsDisplay) CopyArea(src image.Rectangle, dst image.Point) {
+	src = src.Canon()
+	delta := dst.Sub(src.Min)
+	screen := wsd.screenRect()
+	// destination area whose source is in the screen
+	d := src.Intersect(screen).Add(delta)
+	if d.Empty() || delta == (image.Point{}) {
+		return
+	}
+	clips := wsd.clipRects()
+	if len(clips) > 1 && d.Overlaps(src) {
+		// a clip rectangle may overwrite the source of another one
+		p, err := wsd.GetPixelArray(src)
+		if err != nil {
+			return
+		}
+		wsd.putPixelArrayRectMasked(dst, p, image.Rect(0, 0, src.Dx(), src.Dy()))
+		return
+	}
+	for _, c := range clips {
+		wsd.copyRect(d.Intersect(c), delta)
+	}
+}
+
+// Copy the area r - delta to r, r and r - delta must be in the screen
+func (wsd *WsDisplay) copyRect(r image.Rectangle, delta image.Point) {
+	if r.Empty() {
+		return
+	}
+	bpp := wsd.bytesPerPixel()
+	sx, _ := wsd.pixelSteps()
+	// go against the move not to read the copied pixels
+	y0, y1, ys := r.Min.Y, r.Max.Y, 1
+	if delta.Y > 0 {
+		y0, y1, ys = r.Max.Y-1, r.Min.Y-1, -1
+	}
+	n := r.Dx()
+	for y := y0; y != y1; y += ys {
+		if sx == bpp {
+			// copy() has memmove semantics
+			doff := wsd.pixelOffset(r.Min.X, y)
+			soff := wsd.pixelOffset(r.Min.X-delta.X, y-delta.Y)
+			copy(wsd.addr[doff:doff+n*bpp], wsd.addr[soff:soff+n*bpp])
+			continue
+		}
+		x0, x1, xs := r.Min.X, r.Max.X, 1
+		if delta.X > 0 {
+			x0, x1, xs = r.Max.X-1, r.Min.X-1, -1
+		}
+		for x := x0; x != x1; x += xs {
+			doff := wsd.pixelOffset(x, y)
+			soff := wsd.pixelOffset(x-delta.X, y-delta.Y)
+			copy(wsd.addr[doff:doff+bpp], wsd.addr[soff:soff+bpp])
+		}
+	}
+}
+
+// Scroll the content of rect by (dx, dy) in rect. The exposed area is
+// filled with fill, or left as is if fill is nil.
+func (wsd *WsDisplay) Scroll(rect image.Rectangle, dx int, dy int, fill PIXEL) {
+	rect = rect.Canon()
+	wsd.PushClip(rect)
+	defer wsd.PopClip()
+	wsd.CopyArea(rect, rect.Min.Add(image.Pt(dx, dy)))
+	if fill == nil {
+		return
+	}
+	if dx > 0 {
+		wsd.FillBox(image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+dx, rect.Max.Y), fill)
+	} else if dx < 0 {
+		wsd.FillBox(image.Rect(rect.Max.X+dx, rect.Min.Y, rect.Max.X, rect.Max.Y), fill)
+	}
+	if dy > 0 {
+		wsd.FillBox(image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+dy), fill)
+	} else if dy < 0 {
+		wsd.FillBox(image.Rect(rect.Min.X, rect.Max.Y+dy, rect.Max.X, rect.Max.Y), fill)
+	}
+}
@@ -72,12 +72,11 @@ func main() {
 		b := image.Rect(w/2-i, h/2-i, w/2+i, h/2+i)
 		wsd.DrawBox(b, p)
 	}
-	for i := 0; i < 256; i++ {
-		b := image.Rect(0, 0, 256-i, 256-i)
-		c := color.RGBA{uint8(i), uint8(i), uint8(i), 255}
-		p.SetColor(c, wsd.GetRGBmask())
-		wsd.FillBox(b, p)
-	}
+	g := gowsdisplay.NewRadialGradient(image.Pt(0, 0), 255,
+		gowsdisplay.ColorStop{Offset: 0, Color: color.White},
+		gowsdisplay.ColorStop{Offset: 1, Color: color.Black})
+	g.SetDither(true)
+	wsd.FillBoxPaint(image.Rect(0, 0, 256, 256), g)
 
 	for i := 0; i < h; i += 8 {
 		c := color.RGBA{uint8(i%256) / 4, uint8(i%256) / 2, uint8(i % 256), 255}
@@ -0,0 +1,284 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// wspicview - keyboard and mouse input
+
+// +build netbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Commands from the input
+type command int
+
+const (
+	cmdNext command = iota
+	cmdPrev
+	cmdFirst
+	cmdLast
+	cmdZoomIn
+	cmdZoomOut
+	cmdZoomReset
+	cmdFitMode
+	cmdPanLeft
+	cmdPanRight
+	cmdPanUp
+	cmdPanDown
+	cmdPan // pan by the mouse drag
+	cmdOverlay
+	cmdPause
+	cmdQuit
+)
+
+type event struct {
+	cmd    command
+	dx, dy int // for cmdPan
+}
+
+// Keyboard on the wscons tty; the wskbd input comes to the screen's tty.
+type keyboard struct {
+	f    *os.File
+	save syscall.Termios
+}
+
+func openKeyboard(dev string) (*keyboard, error) {
+	f, err := os.OpenFile(dev, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	kbd := &keyboard{f: f}
+	if err := kbd.ioctl(syscall.TIOCGETA, &kbd.save); err != nil {
+		f.Close()
+		return nil, err
+	}
+	raw := kbd.save
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := kbd.ioctl(syscall.TIOCSETA, &raw); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return kbd, nil
+}
+
+func (kbd *keyboard) ioctl(req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, kbd.f.Fd(), req,
+		uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Restore the terminal mode
+func (kbd *keyboard) Close() error {
+	kbd.ioctl(syscall.TIOCSETA, &kbd.save)
+	return kbd.f.Close()
+}
+
+// Read the keys and send the commands
+func (kbd *keyboard) run(ch chan<- event) {
+	buf := make([]byte, 16)
+	var rest []byte
+	for {
+		n, err := kbd.f.Read(buf)
+		if err != nil {
+			return
+		}
+		var cmds []command
+		cmds, rest = parseKeys(append(rest, buf[:n]...))
+		for _, cmd := range cmds {
+			ch <- event{cmd: cmd}
+		}
+		if len(rest) > maxEscape {
+			rest = nil
+		}
+		rest = append([]byte{}, rest...)
+	}
+}
+
+// Key bindings, the arrow keys are "ESC [ A" etc. The incomplete escape
+// sequence at the end is returned as rest to parse with the next input.
+func parseKeys(b []byte) (cmds []command, rest []byte) {
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0x1b {
+			n, cmd, ok := parseEscape(b[i:])
+			if !ok {
+				// incomplete, wait for the rest of the sequence
+				return cmds, b[i:]
+			}
+			if n > 0 {
+				cmds = append(cmds, cmd...)
+				i += n - 1
+			}
+			continue
+		}
+		switch b[i] {
+		case ' ', 'n', '\r', '\n':
+			cmds = append(cmds, cmdNext)
+		case 'p', 'b', 0x08, 0x7f:
+			cmds = append(cmds, cmdPrev)
+		case 'g':
+			cmds = append(cmds, cmdFirst)
+		case 'G':
+			cmds = append(cmds, cmdLast)
+		case '+', '=':
+			cmds = append(cmds, cmdZoomIn)
+		case '-':
+			cmds = append(cmds, cmdZoomOut)
+		case '0':
+			cmds = append(cmds, cmdZoomReset)
+		case 'f':
+			cmds = append(cmds, cmdFitMode)
+		case 'h':
+			cmds = append(cmds, cmdPanLeft)
+		case 'j':
+			cmds = append(cmds, cmdPanDown)
+		case 'k':
+			cmds = append(cmds, cmdPanUp)
+		case 'l':
+			cmds = append(cmds, cmdPanRight)
+		case 'i':
+			cmds = append(cmds, cmdOverlay)
+		case 's':
+			cmds = append(cmds, cmdPause)
+		case 'q':
+			cmds = append(cmds, cmdQuit)
+		}
+	}
+	return cmds, nil
+}
+
+// Maximum length of the escape sequence to wait for the rest
+const maxEscape = 8
+
+// Parse the escape sequence "ESC [ params final" at the top of b.
+// It returns the length and the command, ok is false if b ends before
+// the final byte. n is 0 for a lone ESC, and it's ignored.
+func parseEscape(b []byte) (n int, cmds []command, ok bool) {
+	if len(b) < 2 {
+		return 0, nil, false
+	}
+	if b[1] != '[' {
+		return 0, nil, true
+	}
+	for j := 2; j < len(b); j++ {
+		c := b[j]
+		if c >= 0x30 && c <= 0x3f {
+			// parameter bytes
+			continue
+		}
+		if c < 0x40 || c > 0x7e {
+			// broken sequence, skip "ESC ["
+			return 2, nil, true
+		}
+		switch string(b[2:j]) + string(c) {
+		case "A":
+			cmds = []command{cmdPanUp}
+		case "B":
+			cmds = []command{cmdPanDown}
+		case "C":
+			cmds = []command{cmdPanRight}
+		case "D":
+			cmds = []command{cmdPanLeft}
+		case "5~": // PageUp
+			cmds = []command{cmdPrev}
+		case "6~": // PageDown
+			cmds = []command{cmdNext}
+		}
+		return j + 1, cmds, true
+	}
+	return 0, nil, false
+}
+
+// struct wscons_event in <dev/wscons/wsconsio.h>
+type wsconsEvent struct {
+	Type  uint32
+	Value int32
+	Time  syscall.Timespec
+}
+
+// wscons_event types
+const (
+	wsconsEventMouseUp     = 4
+	wsconsEventMouseDown   = 5
+	wsconsEventMouseDeltaX = 6
+	wsconsEventMouseDeltaY = 7
+	wsconsEventMouseDeltaZ = 10
+)
+
+const maxEvents = 16
+
+// Read the wsmouse events and send the commands. Drag with the left
+// button pans, the wheel zooms, the right button is next and the
+// middle button is previous.
+func runMouse(f *os.File, ch chan<- event) {
+	var evs [maxEvents]wsconsEvent
+	buf := (*[maxEvents * unsafe.Sizeof(wsconsEvent{})]byte)(unsafe.Pointer(&evs[0]))[:]
+	left := false
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, ev := range evs[:n/int(unsafe.Sizeof(wsconsEvent{}))] {
+			switch ev.Type {
+			case wsconsEventMouseDown:
+				switch ev.Value {
+				case 0:
+					left = true
+				case 1:
+					ch <- event{cmd: cmdPrev}
+				case 2:
+					ch <- event{cmd: cmdNext}
+				}
+			case wsconsEventMouseUp:
+				if ev.Value == 0 {
+					left = false
+				}
+			case wsconsEventMouseDeltaX:
+				if left {
+					ch <- event{cmd: cmdPan, dx: int(ev.Value)}
+				}
+			case wsconsEventMouseDeltaY:
+				// wsmouse reports upward motion as positive
+				if left {
+					ch <- event{cmd: cmdPan, dy: -int(ev.Value)}
+				}
+			case wsconsEventMouseDeltaZ:
+				if ev.Value < 0 {
+					ch <- event{cmd: cmdZoomIn}
+				} else if ev.Value > 0 {
+					ch <- event{cmd: cmdZoomOut}
+				}
+			}
+		}
+	}
+}
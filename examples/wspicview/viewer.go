@@ -0,0 +1,352 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// wspicview - picture loader and view state
+
+// +build netbsd
+
+package main
+
+import (
+	"fmt"
+	"github.com/oshimaya/gowsdisplay"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Decoded picture converted for the display
+type picture struct {
+	name    string
+	width   int
+	height  int
+	full    gowsdisplay.PIXELARRAY // original size for zoom
+	img     image.Image
+	fit     gowsdisplay.PIXELARRAY // scaled for fitMode
+	fitMode gowsdisplay.FitMode
+	err     error
+}
+
+type pending struct {
+	done chan struct{}
+	mode gowsdisplay.FitMode
+	pic  *picture
+}
+
+// Load the pictures in the background, the next one is decoded
+// while the current one is displayed.
+type loader struct {
+	wsd   *gowsdisplay.WsDisplay
+	files []string
+	mode  gowsdisplay.FitMode
+	mu    sync.Mutex
+	cache map[int]*pending
+}
+
+func newLoader(wsd *gowsdisplay.WsDisplay, files []string, mode gowsdisplay.FitMode) *loader {
+	return &loader{wsd: wsd, files: files, mode: mode, cache: make(map[int]*pending)}
+}
+
+// Start loading the picture i if it's not loaded, or scaling it if
+// it's loaded for another fit mode. pd.done is closed when it's ready.
+func (l *loader) prefetch(i int) *pending {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	mode := l.mode
+	old, ok := l.cache[i]
+	if ok && old.mode == mode {
+		return old
+	}
+	pd := &pending{done: make(chan struct{}), mode: mode}
+	l.cache[i] = pd
+	go func() {
+		defer close(pd.done)
+		if old != nil {
+			<-old.done
+			if old.pic.err == nil {
+				pd.pic = old.pic.rescaled(l.wsd, mode)
+				return
+			}
+		}
+		pd.pic = l.load(l.files[i], mode)
+	}()
+	return pd
+}
+
+// Keep only the picture i and its neighbours in the cache
+func (l *loader) trim(i int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.cache {
+		if k < i-1 || k > i+1 {
+			delete(l.cache, k)
+		}
+	}
+}
+
+func (l *loader) setMode(mode gowsdisplay.FitMode) {
+	l.mu.Lock()
+	l.mode = mode
+	l.mu.Unlock()
+}
+
+func (l *loader) load(fname string, mode gowsdisplay.FitMode) *picture {
+	pic := &picture{name: filepath.Base(fname)}
+	img, err := loadPic(fname)
+	if err != nil {
+		pic.err = err
+		return pic
+	}
+	b := img.Bounds()
+	pic.img = img
+	pic.width = b.Dx()
+	pic.height = b.Dy()
+	pic.full, err = l.wsd.NewScaledPixelArray(img, b.Dx(), b.Dy(), gowsdisplay.Bilinear)
+	if err != nil {
+		pic.err = err
+		return pic
+	}
+	pic.err = pic.scale(l.wsd, mode)
+	return pic
+}
+
+func loadPic(fname string) (image.Image, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// decode with EXIF orientation of JPEG
+	img, _, err := gowsdisplay.DecodeImage(f)
+	return img, err
+}
+
+// Make the scaled image for the fit mode
+func (pic *picture) scale(wsd *gowsdisplay.WsDisplay, mode gowsdisplay.FitMode) (err error) {
+	r := pic.fitRect(wsd, mode)
+	if r.Empty() {
+		return fmt.Errorf("%s: empty image", pic.name)
+	}
+	pic.fit, err = wsd.NewScaledPixelArray(pic.img, r.Dx(), r.Dy(), gowsdisplay.Bilinear)
+	pic.fitMode = mode
+	return err
+}
+
+// Copy of the picture scaled for the fit mode
+func (pic *picture) rescaled(wsd *gowsdisplay.WsDisplay, mode gowsdisplay.FitMode) *picture {
+	p := *pic
+	p.err = p.scale(wsd, mode)
+	return &p
+}
+
+func (pic *picture) fitRect(wsd *gowsdisplay.WsDisplay, mode gowsdisplay.FitMode) image.Rectangle {
+	screen := image.Rect(0, 0, wsd.GetWidth(), wsd.GetHeight())
+	return gowsdisplay.FitRect(pic.width, pic.height, screen, mode)
+}
+
+// View state of the current picture
+type viewer struct {
+	wsd     *gowsdisplay.WsDisplay
+	loader  *loader
+	index   int
+	pic     *picture
+	want    *pending // the picture i being loaded to show
+	mode    gowsdisplay.FitMode
+	zoom    float64 // relative to the fit size
+	pan     image.Point
+	overlay bool
+}
+
+var fitModes = []gowsdisplay.FitMode{
+	gowsdisplay.FitShrink,
+	gowsdisplay.FitContain,
+	gowsdisplay.FitCover,
+	gowsdisplay.FitCenter,
+	gowsdisplay.FitStretch,
+}
+
+var fitNames = map[gowsdisplay.FitMode]string{
+	gowsdisplay.FitShrink:  "shrink",
+	gowsdisplay.FitContain: "contain",
+	gowsdisplay.FitCover:   "cover",
+	gowsdisplay.FitCenter:  "center",
+	gowsdisplay.FitStretch: "stretch",
+}
+
+const (
+	zoomStep = 1.25
+	zoomMax  = 16.0
+	panStep  = 64
+)
+
+// Show the picture i, it's shown by loaded() if it's not ready yet.
+// The decoding and scaling are done in the background, so the events
+// are handled while loading.
+func (v *viewer) show(i int) {
+	v.index = i
+	v.want = v.loader.prefetch(i)
+	select {
+	case <-v.want.done:
+		v.loaded()
+	default:
+		v.drawText(fmt.Sprintf("Loading %s", filepath.Base(v.loader.files[i])))
+	}
+}
+
+// Channel closed when the picture to show is ready, nil if nothing
+// is loading
+func (v *viewer) loading() <-chan struct{} {
+	if v.want == nil {
+		return nil
+	}
+	return v.want.done
+}
+
+// Show the picture which is ready
+func (v *viewer) loaded() {
+	v.pic = v.want.pic
+	v.want = nil
+	v.zoom = 1
+	v.pan = image.Point{}
+	v.loader.trim(v.index)
+	if v.index+1 < len(v.loader.files) {
+		v.loader.prefetch(v.index + 1)
+	}
+	v.draw()
+}
+
+func (v *viewer) setMode(mode gowsdisplay.FitMode) {
+	v.mode = mode
+	v.loader.setMode(mode)
+	v.show(v.index)
+}
+
+func (v *viewer) nextMode() {
+	for i, m := range fitModes {
+		if m == v.mode {
+			v.setMode(fitModes[(i+1)%len(fitModes)])
+			return
+		}
+	}
+	v.setMode(fitModes[0])
+}
+
+func (v *viewer) setZoom(z float64) {
+	if z < 1/zoomMax {
+		z = 1 / zoomMax
+	}
+	if z > zoomMax {
+		z = zoomMax
+	}
+	v.zoom = z
+	v.movePan(0, 0)
+	v.draw()
+}
+
+// Move the view, the picture doesn't leave the screen
+func (v *viewer) movePan(dx int, dy int) {
+	if v.pic == nil || v.pic.err != nil {
+		return
+	}
+	r := v.viewRect()
+	lx := (r.Dx() - v.wsd.GetWidth()) / 2
+	ly := (r.Dy() - v.wsd.GetHeight()) / 2
+	v.pan.X = clampPan(v.pan.X+dx, lx)
+	v.pan.Y = clampPan(v.pan.Y+dy, ly)
+}
+
+func clampPan(p int, limit int) int {
+	if limit < 0 {
+		limit = 0
+	}
+	if p < -limit {
+		return -limit
+	}
+	if p > limit {
+		return limit
+	}
+	return p
+}
+
+// Rectangle of the picture on the screen with the zoom and pan
+func (v *viewer) viewRect() image.Rectangle {
+	base := v.pic.fitRect(v.wsd, v.mode)
+	w := int(float64(base.Dx())*v.zoom + 0.5)
+	h := int(float64(base.Dy())*v.zoom + 0.5)
+	x := (v.wsd.GetWidth()-w)/2 + v.pan.X
+	y := (v.wsd.GetHeight()-h)/2 + v.pan.Y
+	return image.Rect(x, y, x+w, y+h)
+}
+
+func (v *viewer) draw() {
+	if v.pic == nil {
+		return
+	}
+	screen := image.Rect(0, 0, v.wsd.GetWidth(), v.wsd.GetHeight())
+	black := v.wsd.NewPixel(color.Black)
+	if v.pic.err != nil {
+		v.wsd.Clear()
+		v.drawText(fmt.Sprintf("%s: %v", v.pic.name, v.pic.err))
+		return
+	}
+	r := v.viewRect()
+	// clear only outside of the picture
+	for _, s := range []image.Rectangle{
+		image.Rect(screen.Min.X, screen.Min.Y, screen.Max.X, r.Min.Y),
+		image.Rect(screen.Min.X, r.Max.Y, screen.Max.X, screen.Max.Y),
+		image.Rect(screen.Min.X, r.Min.Y, r.Min.X, r.Max.Y),
+		image.Rect(r.Max.X, r.Min.Y, screen.Max.X, r.Max.Y),
+	} {
+		v.wsd.FillBox(s.Intersect(screen), black)
+	}
+	if v.zoom == 1 && v.pan == (image.Point{}) {
+		v.wsd.PutPixelArray(r.Min.X, r.Min.Y, v.pic.fit)
+	} else {
+		// source pixel centers are mapped into the view rectangle
+		sx := float64(r.Dx()) / float64(v.pic.width)
+		sy := float64(r.Dy()) / float64(v.pic.height)
+		m := gowsdisplay.TranslateAffine(float64(r.Min.X)+sx/2-0.5,
+			float64(r.Min.Y)+sy/2-0.5).Scale(sx, sy)
+		v.wsd.PutPixelArrayTransform(v.pic.full, m, gowsdisplay.Nearest)
+	}
+	if v.overlay {
+		v.drawText(fmt.Sprintf("%d/%d %s %dx%d %s %d%%",
+			v.index+1, len(v.loader.files), v.pic.name,
+			v.pic.width, v.pic.height, fitNames[v.mode],
+			int(100*float64(r.Dx())/float64(v.pic.width)+0.5)))
+	}
+}
+
+// Draw the text at the top left with the background
+func (v *viewer) drawText(s string) {
+	scale := 1
+	if v.wsd.GetWidth() >= 1024 {
+		scale = 2
+	}
+	sz := gowsdisplay.TextSize(s, scale)
+	pad := 2 * scale
+	v.wsd.FillBox(image.Rect(0, 0, sz.X+pad*2, sz.Y+pad*2), v.wsd.NewPixel(color.Black))
+	v.wsd.DrawText(pad, pad, s, scale, v.wsd.NewPixel(color.White))
+}
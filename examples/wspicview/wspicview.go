@@ -25,25 +25,71 @@
 
 // wspicview - GoWsDisplay example
 //
-// Usage: 
-//    wspicvew {picturefile ...}
+// Usage:
+//    wspicview [-d device] [-i interval] [-fit mode] [-m mouse] [-loop]
+//              [-overlay=false] {picturefile | directory | pattern ...}
+//
+// Keys:
+//    space, n, PageDown   next picture
+//    p, b, PageUp         previous picture
+//    g, G                 first, last picture
+//    +, -, 0              zoom in, zoom out, fit
+//    arrows, h j k l      pan (previous/next by left/right when not zoomed)
+//    f                    change fit mode
+//    i                    show/hide the file name
+//    s                    pause/resume the slideshow
+//    q                    quit
+//
+// Mouse: drag to pan, wheel to zoom, right/middle button for next/previous.
 //
 
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/oshimaya/gowsdisplay"
-	"image"
+	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 )
 
+var picExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
 func main() {
+	dev := flag.String("d", "/dev/ttyE1", "wsdisplay tty device")
+	interval := flag.Duration("i", 5*time.Second, "slideshow interval, 0 for manual")
+	fit := flag.String("fit", "shrink", "fit mode: shrink, contain, cover, center or stretch")
+	mouse := flag.String("m", "/dev/wsmouse", "wsmouse device, empty for no mouse")
+	loop := flag.Bool("loop", false, "repeat from the first picture")
+	overlay := flag.Bool("overlay", true, "show the file name and index")
+	flag.Parse()
+
+	mode, ok := parseFitMode(*fit)
+	if !ok {
+		fmt.Println("Unknown fit mode: ", *fit)
+		return
+	}
+	files := listFiles(flag.Args())
+	if len(files) == 0 {
+		fmt.Println("No picture files")
+		return
+	}
 
-	wsd := gowsdisplay.NewWsDisplay("/dev/ttyE1")
+	wsd := gowsdisplay.NewWsDisplay(*dev)
 	err := wsd.Open()
 	if err != nil {
 		fmt.Println("Open: ", err)
@@ -56,46 +102,201 @@ func main() {
 		return
 	}
 
+	// Close restores the text mode
 	defer wsd.Close()
 
 	if wsd.GetPixelType() != gowsdisplay.FBRGB {
 		fmt.Println("Sorry only support RGB type framebuffer.")
 		return
 	}
-	for i, argv := range os.Args {
-		if i == 0 {
-			continue
-		}
-		img, err := loadPic(argv)
+
+	events := make(chan event, 16)
+	kbd, err := openKeyboard(*dev)
+	if err != nil {
+		fmt.Println("Keyboard: ", err)
+	} else {
+		defer kbd.Close()
+		go kbd.run(events)
+	}
+	if *mouse != "" {
+		m, err := os.Open(*mouse)
 		if err != nil {
-			fmt.Println("Image load: ", err)
-			continue
+			fmt.Println("Mouse: ", err)
+		} else {
+			defer m.Close()
+			go runMouse(m, events)
 		}
+	}
 
-		// Create Pixel Array (for pattern data)
-
-		p, err := wsd.NewPixelArray()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
 
-		// Store image data to Pixel Array
+	v := &viewer{
+		wsd:     wsd,
+		loader:  newLoader(wsd, files, mode),
+		mode:    mode,
+		overlay: *overlay,
+	}
+	v.show(0)
 
-		p.StoreImage(img, wsd.GetRGBmask())
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	paused := *interval <= 0
+	resetTicker := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker = nil
+			tick = nil
+		}
+		if !paused {
+			ticker = time.NewTicker(*interval)
+			tick = ticker.C
+		}
+	}
+	resetTicker()
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
 
-		// Display Pixel Array to wsdisplay
+	// go to the picture i, returns false at the end of slideshow
+	move := func(i int) bool {
+		n := len(files)
+		if i < 0 || i >= n {
+			if !*loop {
+				return false
+			}
+			i = (i + n) % n
+		}
+		if i != v.index {
+			v.show(i)
+		}
+		return true
+	}
 
-		wsd.Clear()
-		wsd.PutPixelArray(0, 0, p)
+	for {
+		select {
+		case <-sigs:
+			return
+		case <-v.loading():
+			v.loaded()
+		case <-tick:
+			if !move(v.index + 1) {
+				return
+			}
+		case ev := <-events:
+			switch ev.cmd {
+			case cmdNext:
+				move(v.index + 1)
+				resetTicker()
+			case cmdPrev:
+				move(v.index - 1)
+				resetTicker()
+			case cmdFirst:
+				move(0)
+				resetTicker()
+			case cmdLast:
+				move(len(files) - 1)
+				resetTicker()
+			case cmdZoomIn:
+				v.setZoom(v.zoom * zoomStep)
+			case cmdZoomOut:
+				v.setZoom(v.zoom / zoomStep)
+			case cmdZoomReset:
+				v.pan.X, v.pan.Y = 0, 0
+				v.setZoom(1)
+			case cmdFitMode:
+				v.nextMode()
+			case cmdPanLeft, cmdPanRight, cmdPanUp, cmdPanDown:
+				if v.zoom <= 1 && (ev.cmd == cmdPanLeft || ev.cmd == cmdPanRight) {
+					if ev.cmd == cmdPanLeft {
+						move(v.index - 1)
+					} else {
+						move(v.index + 1)
+					}
+					resetTicker()
+					break
+				}
+				dx, dy := 0, 0
+				switch ev.cmd {
+				case cmdPanLeft:
+					dx = panStep
+				case cmdPanRight:
+					dx = -panStep
+				case cmdPanUp:
+					dy = panStep
+				case cmdPanDown:
+					dy = -panStep
+				}
+				v.movePan(dx, dy)
+				v.draw()
+			case cmdPan:
+				v.movePan(ev.dx, ev.dy)
+				v.draw()
+			case cmdOverlay:
+				v.overlay = !v.overlay
+				v.draw()
+			case cmdPause:
+				paused = !paused && *interval > 0
+				resetTicker()
+			case cmdQuit:
+				return
+			}
+		}
+	}
+}
 
-		time.Sleep(time.Second * 5)
+func parseFitMode(s string) (gowsdisplay.FitMode, bool) {
+	for m, name := range fitNames {
+		if name == s {
+			return m, true
+		}
 	}
+	return gowsdisplay.FitShrink, false
 }
 
-func loadPic(fname string) (image.Image, error) {
-	f, err := os.Open(fname)
-	if err != nil {
-		fmt.Println("Open:", err)
-		return nil, err
+// Expand the arguments to the picture files; a directory is read
+// for the pictures in it and a pattern is matched by filepath.Glob.
+func listFiles(args []string) []string {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var files []string
+	for _, arg := range args {
+		names := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			m, err := filepath.Glob(arg)
+			if err != nil {
+				fmt.Println("Pattern: ", err)
+				continue
+			}
+			names = m
+		}
+		for _, name := range names {
+			fi, err := os.Stat(name)
+			if err != nil {
+				fmt.Println("Stat: ", err)
+				continue
+			}
+			if !fi.IsDir() {
+				files = append(files, name)
+				continue
+			}
+			ents, err := ioutil.ReadDir(name)
+			if err != nil {
+				fmt.Println("ReadDir: ", err)
+				continue
+			}
+			var dir []string
+			for _, e := range ents {
+				if !e.IsDir() && picExts[strings.ToLower(filepath.Ext(e.Name()))] {
+					dir = append(dir, filepath.Join(name, e.Name()))
+				}
+			}
+			sort.Strings(dir)
+			files = append(files, dir...)
+		}
 	}
-	defer f.Close()
-	img, _, err := image.Decode(f)
-	return img, err
+	return files
 }
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Flood fill and boundary fill
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bytes"
+	"image"
+)
+
+// Replace the 4-connected region of the color at (x, y) with the pixel.
+// The pixels are compared by the raw data, and the region is limited to
+// the clip region.
+func (wsd *WsDisplay) FloodFill(x int, y int, fill PIXEL) {
+	b := pixelBytes(fill)
+	bpp := wsd.bytesPerPixel()
+	if len(b) != bpp || !wsd.inClip(x, y) {
+		return
+	}
+	off := wsd.pixelOffset(x, y)
+	seed := append([]byte{}, wsd.addr[off:off+bpp]...)
+	if bytes.Equal(seed, b) {
+		return
+	}
+	wsd.spanFill(x, y, fill, func(off int) bool {
+		return bytes.Equal(wsd.addr[off:off+bpp], seed)
+	})
+}
+
+// Fill the 4-connected region around (x, y) bounded by the boundary
+// pixels with the pixel. The pixels already same as fill also stop the
+// filling, and the region is limited to the clip region.
+func (wsd *WsDisplay) BoundaryFill(x int, y int, boundary PIXEL, fill PIXEL) {
+	bb := pixelBytes(boundary)
+	fb := pixelBytes(fill)
+	bpp := wsd.bytesPerPixel()
+	if len(bb) != bpp || len(fb) != bpp {
+		return
+	}
+	wsd.spanFill(x, y, fill, func(off int) bool {
+		d := wsd.addr[off : off+bpp]
+		return !bytes.Equal(d, bb) && !bytes.Equal(d, fb)
+	})
+}
+
+// Scanline fill from (x, y), inside(off) tells the pixel at the byte
+// offset is to be filled. inside must be false for the filled pixels.
+// The seeds are kept in a stack, one per span, not by recursion.
+func (wsd *WsDisplay) spanFill(x int, y int, fill PIXEL, inside func(off int) bool) {
+	in := func(x int, y int) bool {
+		return wsd.inClip(x, y) && inside(wsd.pixelOffset(x, y))
+	}
+	stack := []image.Point{{x, y}}
+	for len(stack) > 0 {
+		pt := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !in(pt.X, pt.Y) {
+			continue
+		}
+		x0 := pt.X
+		for in(x0-1, pt.Y) {
+			x0--
+		}
+		x1 := pt.X + 1
+		for in(x1, pt.Y) {
+			x1++
+		}
+		wsd.fillSpan(x0, x1, pt.Y, fill)
+		// push the start of each span in the lines above and below
+		for _, ny := range []int{pt.Y - 1, pt.Y + 1} {
+			span := false
+			for nx := x0; nx < x1; nx++ {
+				if in(nx, ny) {
+					if !span {
+						stack = append(stack, image.Pt(nx, ny))
+						span = true
+					}
+				} else {
+					span = false
+				}
+			}
+		}
+	}
+}
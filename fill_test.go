@@ -0,0 +1,170 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for FloodFill and BoundaryFill
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	fillWall = color.RGBA{255, 255, 255, 255}
+	fillNew  = color.RGBA{255, 0, 0, 255}
+)
+
+// Count the pixels of the color c
+func countColor(wsd *WsDisplay, c color.RGBA) int {
+	want := wsd.pixelColor(wsd.NewPixel(c))
+	n := 0
+	for y := 0; y < wsd.GetHeight(); y++ {
+		for x := 0; x < wsd.GetWidth(); x++ {
+			if wsd.pixelColor(wsd.GetPixel(x, y)) == want {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// 20x20 display with the box outline (5,5)-(15,15), gap opens the
+// one pixel hole at (10, 5)
+func fillTestDisplay(t *testing.T, depth int, gap bool) *WsDisplay {
+	wsd := newTestDisplay(t, 20, 20, depth)
+	wsd.DrawBox(image.Rect(5, 5, 15, 15), wsd.NewPixel(fillWall))
+	if gap {
+		wsd.SetPixel(10, 5, wsd.NewPixel(color.Black))
+	}
+	return wsd
+}
+
+func TestFloodFill(t *testing.T) {
+	for _, depth := range []int{16, 24, 32} {
+		// inside of the box is 8x8
+		wsd := fillTestDisplay(t, depth, false)
+		wsd.FloodFill(10, 10, wsd.NewPixel(fillNew))
+		if n := countColor(wsd, fillNew); n != 64 {
+			t.Errorf("depth %d bounded: %d pixels filled, want 64", depth, n)
+		}
+
+		// the gap connects the inside and outside
+		wsd = fillTestDisplay(t, depth, true)
+		wsd.FloodFill(10, 10, wsd.NewPixel(fillNew))
+		if n := countColor(wsd, fillNew); n != 400-35 {
+			t.Errorf("depth %d gap: %d pixels filled, want %d", depth, n, 400-35)
+		}
+
+		// the wall itself is the region of its color
+		wsd = fillTestDisplay(t, depth, false)
+		wsd.FloodFill(5, 5, wsd.NewPixel(fillNew))
+		if n := countColor(wsd, fillNew); n != 36 {
+			t.Errorf("depth %d wall: %d pixels filled, want 36", depth, n)
+		}
+	}
+}
+
+func TestFloodFillEdges(t *testing.T) {
+	for _, seed := range []image.Point{
+		{0, 0}, {19, 0}, {0, 19}, {19, 19}, // corners
+		{10, 0}, {0, 10}, {19, 10}, {10, 19}, // edges
+	} {
+		wsd := fillTestDisplay(t, 32, false)
+		wsd.FloodFill(seed.X, seed.Y, wsd.NewPixel(fillNew))
+		if n := countColor(wsd, fillNew); n != 400-100 {
+			t.Errorf("seed %v: %d pixels filled, want %d", seed, n, 400-100)
+		}
+	}
+	// out of the screen does nothing
+	wsd := fillTestDisplay(t, 32, false)
+	wsd.FloodFill(-1, 0, wsd.NewPixel(fillNew))
+	wsd.FloodFill(20, 20, wsd.NewPixel(fillNew))
+	if n := countColor(wsd, fillNew); n != 0 {
+		t.Errorf("seed out of the screen: %d pixels filled", n)
+	}
+}
+
+func TestFloodFillSameColor(t *testing.T) {
+	wsd := fillTestDisplay(t, 32, false)
+	before := append([]byte{}, wsd.GetBuffer()...)
+	wsd.FloodFill(10, 10, wsd.NewPixel(color.Black))
+	if string(before) != string(wsd.GetBuffer()) {
+		t.Error("fill with the seed color changes the screen")
+	}
+}
+
+func TestFloodFillClip(t *testing.T) {
+	wsd := fillTestDisplay(t, 32, false)
+	wsd.SetClip(image.Rect(0, 0, 10, 20))
+	wsd.FloodFill(10-1, 10, wsd.NewPixel(fillNew))
+	if n := countColor(wsd, fillNew); n != 32 {
+		t.Errorf("clipped: %d pixels filled, want 32", n)
+	}
+}
+
+func TestBoundaryFill(t *testing.T) {
+	for _, depth := range []int{16, 24, 32} {
+		// the inside has another color, it's filled too
+		wsd := fillTestDisplay(t, depth, false)
+		wsd.FillBox(image.Rect(8, 8, 12, 12), wsd.NewPixel(color.RGBA{0, 0, 255, 255}))
+		wsd.BoundaryFill(10, 10, wsd.NewPixel(fillWall), wsd.NewPixel(fillNew))
+		if n := countColor(wsd, fillNew); n != 64 {
+			t.Errorf("depth %d bounded: %d pixels filled, want 64", depth, n)
+		}
+
+		wsd = fillTestDisplay(t, depth, true)
+		wsd.BoundaryFill(10, 10, wsd.NewPixel(fillWall), wsd.NewPixel(fillNew))
+		if n := countColor(wsd, fillNew); n != 400-35 {
+			t.Errorf("depth %d gap: %d pixels filled, want %d", depth, n, 400-35)
+		}
+	}
+	for _, seed := range []image.Point{{0, 0}, {19, 19}, {19, 0}, {0, 10}} {
+		wsd := fillTestDisplay(t, 32, false)
+		wsd.BoundaryFill(seed.X, seed.Y, wsd.NewPixel(fillWall), wsd.NewPixel(fillNew))
+		if n := countColor(wsd, fillNew); n != 400-100 {
+			t.Errorf("seed %v: %d pixels filled, want %d", seed, n, 400-100)
+		}
+	}
+	// seed on the boundary does nothing
+	wsd := fillTestDisplay(t, 32, false)
+	wsd.BoundaryFill(5, 5, wsd.NewPixel(fillWall), wsd.NewPixel(fillNew))
+	if n := countColor(wsd, fillNew); n != 0 {
+		t.Errorf("seed on the boundary: %d pixels filled", n)
+	}
+}
+
+func TestFloodFillRotation(t *testing.T) {
+	wsd := newTestDisplay(t, 30, 20, 32)
+	if err := wsd.SetRotation(Rotate90); err != nil {
+		t.Fatal(err)
+	}
+	wsd.DrawLine(image.Pt(0, 10), image.Pt(19, 10), wsd.NewPixel(fillWall))
+	wsd.FloodFill(0, 0, wsd.NewPixel(fillNew))
+	if n := countColor(wsd, fillNew); n != 20*10 {
+		t.Errorf("rotated: %d pixels filled, want 200", n)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Golden image tests of the drawing primitives
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden images in testdata")
+
+var goldenTests = []struct {
+	name string
+	draw func(wsd *WsDisplay)
+}{
+	{"lines", func(wsd *WsDisplay) {
+		p := wsd.NewPixel(color.RGBA{255, 255, 255, 255})
+		for i := 0; i <= 60; i += 10 {
+			wsd.DrawLine(image.Pt(2, 2), image.Pt(61, 2+i), p)
+			wsd.DrawLine(image.Pt(2, 2), image.Pt(2+i, 61), p)
+		}
+		wsd.DrawLine(image.Pt(61, 61), image.Pt(40, 30), wsd.NewPixel(color.RGBA{255, 0, 0, 255}))
+		wsd.DrawPolyline([]image.Point{{30, 60}, {45, 45}, {60, 60}}, wsd.NewPixel(color.RGBA{0, 255, 0, 255}))
+	}},
+	{"boxes", func(wsd *WsDisplay) {
+		wsd.FillBox(image.Rect(4, 4, 30, 20), wsd.NewPixel(color.RGBA{0, 0, 255, 255}))
+		wsd.DrawBox(image.Rect(4, 4, 30, 20), wsd.NewPixel(color.RGBA{255, 255, 255, 255}))
+		wsd.DrawBox(image.Rect(34, 4, 35, 40), wsd.NewPixel(color.RGBA{255, 0, 0, 255}))
+		wsd.FillBox(image.Rect(40, 30, 70, 70), wsd.NewPixel(color.RGBA{0, 255, 0, 255}))
+		wsd.FillRoundRect(image.Rect(4, 30, 34, 60), 8, wsd.NewPixel(color.RGBA{255, 255, 0, 255}))
+		wsd.DrawRoundRect(image.Rect(4, 30, 34, 60), 8, wsd.NewPixel(color.RGBA{255, 0, 255, 255}))
+	}},
+	{"circles", func(wsd *WsDisplay) {
+		wsd.FillCircle(20, 20, 15, wsd.NewPixel(color.RGBA{0, 0, 255, 255}))
+		wsd.DrawCircle(20, 20, 15, wsd.NewPixel(color.RGBA{255, 255, 255, 255}))
+		wsd.DrawCircle(50, 12, 1, wsd.NewPixel(color.RGBA{255, 0, 0, 255}))
+		wsd.FillEllipse(44, 44, 18, 8, wsd.NewPixel(color.RGBA{0, 255, 0, 255}))
+		wsd.DrawEllipse(20, 50, 6, 12, wsd.NewPixel(color.RGBA{255, 255, 0, 255}))
+		wsd.FillPie(50, 20, 10, 10, 0, 270, wsd.NewPixel(color.RGBA{255, 0, 255, 255}))
+		wsd.DrawArc(50, 20, 13, 13, 270, 90, wsd.NewPixel(color.RGBA{0, 255, 255, 255}))
+	}},
+	{"polygons", func(wsd *WsDisplay) {
+		star := []image.Point{{32, 2}, {50, 60}, {2, 22}, {62, 22}, {14, 60}}
+		wsd.FillPolygon(star, EvenOdd, wsd.NewPixel(color.RGBA{255, 0, 0, 255}))
+		moved := make([]image.Point, len(star))
+		for i, pt := range star {
+			moved[i] = image.Pt(pt.X/2+32, pt.Y/2+32)
+		}
+		wsd.FillPolygon(moved, NonZero, wsd.NewPixel(color.RGBA{0, 255, 0, 255}))
+		wsd.FillPolygon([]image.Point{{2, 62}, {2, 40}, {20, 62}}, NonZero, wsd.NewPixel(color.RGBA{0, 0, 255, 255}))
+	}},
+	{"antialias", func(wsd *WsDisplay) {
+		w := wsd.NewPixel(color.RGBA{255, 255, 255, 255})
+		wsd.DrawLineAA(image.Pt(2, 2), image.Pt(61, 20), w)
+		wsd.DrawLineAA(image.Pt(2, 2), image.Pt(20, 61), w)
+		wsd.DrawCircleAA(40, 40, 18, w)
+		wsd.DrawEllipseAA(16, 46, 12, 6, wsd.NewPixel(color.RGBA{255, 255, 0, 255}))
+		wsd.FillPolygonAA([]image.Point{{30, 4}, {60, 10}, {40, 30}}, NonZero, wsd.NewPixel(color.RGBA{0, 255, 255, 255}))
+	}},
+	{"blit", func(wsd *WsDisplay) {
+		wsd.FillBox(wsd.screenRect(), wsd.NewPixel(color.RGBA{64, 64, 64, 255}))
+		// whole screen, then the clip region only
+		wsd.Clear()
+		wsd.FillBox(image.Rect(0, 0, 64, 48), wsd.NewPixel(color.RGBA{128, 128, 255, 255}))
+		wsd.SetClip(image.Rect(40, 40, 60, 60))
+		wsd.Clear()
+		wsd.ResetClip()
+
+		// 20x20 gradient, the corners are transparent
+		img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 20; x++ {
+				if (x < 5 || x >= 15) && (y < 5 || y >= 15) {
+					continue
+				}
+				img.SetRGBA(x, y, color.RGBA{uint8(x * 12), uint8(y * 12), 200, 255})
+			}
+		}
+		p, err := wsd.NewScaledPixelArray(img, 20, 20, Nearest)
+		if err != nil {
+			panic(err)
+		}
+		// the mask is ignored
+		wsd.PutPixelArray(2, 2, p)
+		// masked, partly out of the screen
+		wsd.PutPixelArrayRect(image.Pt(30, 2), p, image.Rect(0, 0, 20, 20))
+		wsd.PutPixelArrayRect(image.Pt(54, 36), p, image.Rect(5, 0, 20, 20))
+		wsd.PutPixelArrayRect(image.Pt(10, 40), p, image.Rect(0, 5, 10, 15))
+	}},
+}
+
+// Get the screen as image.RGBA
+func screenImage(wsd *WsDisplay) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, wsd.GetWidth(), wsd.GetHeight()))
+	for y := 0; y < wsd.GetHeight(); y++ {
+		for x := 0; x < wsd.GetWidth(); x++ {
+			img.SetRGBA(x, y, wsd.pixelColor(wsd.GetPixel(x, y)))
+		}
+	}
+	return img
+}
+
+func readPNG(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Compare the image with the golden image, and report the first difference
+func compareGolden(t *testing.T, name string, got *image.RGBA) {
+	t.Helper()
+	file := filepath.Join("testdata", name+".png")
+	if *update {
+		if err := writePNG(file, got); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := readPNG(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.Bounds() != got.Bounds() {
+		t.Fatalf("%s: bounds %v, want %v", name, got.Bounds(), want.Bounds())
+	}
+	diff := 0
+	for y := got.Rect.Min.Y; y < got.Rect.Max.Y; y++ {
+		for x := got.Rect.Min.X; x < got.Rect.Max.X; x++ {
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			if g := got.RGBAAt(x, y); g != w {
+				if diff == 0 {
+					t.Errorf("%s: (%d, %d) is %v, want %v", name, x, y, g, w)
+				}
+				diff++
+			}
+		}
+	}
+	if diff > 0 {
+		t.Errorf("%s: %d pixels differ", name, diff)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	for _, tt := range goldenTests {
+		wsd := newTestDisplay(t, 64, 64, 32)
+		tt.draw(wsd)
+		compareGolden(t, tt.name, screenImage(wsd))
+
+		// 24bit has the same 8:8:8 mask, so the result is same
+		wsd24 := newTestDisplay(t, 64, 64, 24)
+		tt.draw(wsd24)
+		compareGolden(t, tt.name, screenImage(wsd24))
+
+		// 16bit has its own images for the 5:6:5 colors
+		wsd16 := newTestDisplay(t, 64, 64, 16)
+		tt.draw(wsd16)
+		compareGolden(t, tt.name+"-16", screenImage(wsd16))
+	}
+}
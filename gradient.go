@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Gradient paints
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+type GradientKind int
+
+const (
+	LinearGradient GradientKind = iota // along the line from p0 to p1
+	RadialGradient                     // from the center to the radius
+	ConicGradient                      // around the center from the angle
+)
+
+// Color at the offset 0.0-1.0 of the gradient
+type ColorStop struct {
+	Offset float64
+	Color  color.Color
+}
+
+type gradientStop struct {
+	offset float64
+	c      [4]float64 // premultiplied RGBA 0-255
+}
+
+// Gradient is the Paint of the colors changing by the position.
+// The coordinates are in the screen, an integer point is the center
+// of the pixel. The colors are padded out of the range.
+type Gradient struct {
+	kind   GradientKind
+	p0     fpoint // start point or center
+	p1     fpoint // end point of the linear gradient
+	radius float64
+	angle  float64 // start angle of the conic gradient, in degrees
+	stops  []gradientStop
+	dither bool
+}
+
+// Create linear gradient from p0 (offset 0) to p1 (offset 1)
+func NewLinearGradient(p0 image.Point, p1 image.Point, stops ...ColorStop) *Gradient {
+	g := &Gradient{kind: LinearGradient,
+		p0: fpoint{float64(p0.X), float64(p0.Y)},
+		p1: fpoint{float64(p1.X), float64(p1.Y)}}
+	g.SetStops(stops...)
+	return g
+}
+
+// Create radial gradient from the center (offset 0) to the radius r (offset 1)
+func NewRadialGradient(center image.Point, r int, stops ...ColorStop) *Gradient {
+	g := &Gradient{kind: RadialGradient,
+		p0:     fpoint{float64(center.X), float64(center.Y)},
+		radius: float64(r)}
+	g.SetStops(stops...)
+	return g
+}
+
+// Create conic gradient around the center from the angle in degrees,
+// clockwise on the screen from the positive x axis, same as DrawArc.
+func NewConicGradient(center image.Point, angle float64, stops ...ColorStop) *Gradient {
+	g := &Gradient{kind: ConicGradient,
+		p0:    fpoint{float64(center.X), float64(center.Y)},
+		angle: angle}
+	g.SetStops(stops...)
+	return g
+}
+
+// Set the color stops, they are sorted by the offset
+func (g *Gradient) SetStops(stops ...ColorStop) {
+	g.stops = g.stops[:0]
+	for _, s := range stops {
+		g.AddStop(s.Offset, s.Color)
+	}
+}
+
+// Add the color stop, the stops of the same offset keep the order
+func (g *Gradient) AddStop(offset float64, c color.Color) {
+	r, gr, b, a := c.RGBA()
+	s := gradientStop{offset, [4]float64{
+		float64(r) / 257, float64(gr) / 257, float64(b) / 257, float64(a) / 257}}
+	i := sort.Search(len(g.stops), func(i int) bool { return g.stops[i].offset > offset })
+	g.stops = append(g.stops, gradientStop{})
+	copy(g.stops[i+1:], g.stops[i:])
+	g.stops[i] = s
+}
+
+// Enable the ordered dithering to hide the banding on 16bpp displays
+func (g *Gradient) SetDither(dither bool) {
+	g.dither = dither
+}
+
+func (g *Gradient) GetKind() GradientKind {
+	return g.kind
+}
+
+// Offset of the gradient at (x, y)
+func (g *Gradient) offset(x float64, y float64) float64 {
+	dx := x - g.p0.x
+	dy := y - g.p0.y
+	switch g.kind {
+	case LinearGradient:
+		vx := g.p1.x - g.p0.x
+		vy := g.p1.y - g.p0.y
+		l := vx*vx + vy*vy
+		if l == 0 {
+			return 0
+		}
+		return (dx*vx + dy*vy) / l
+	case RadialGradient:
+		if g.radius <= 0 {
+			return 1
+		}
+		return math.Hypot(dx, dy) / g.radius
+	case ConicGradient:
+		t := math.Atan2(dy, dx)*180/math.Pi - g.angle
+		t = math.Mod(t, 360)
+		if t < 0 {
+			t += 360
+		}
+		return t / 360
+	}
+	return 0
+}
+
+// Color at the offset t
+func (g *Gradient) colorAt(t float64) color.RGBA {
+	n := len(g.stops)
+	if n == 0 {
+		return color.RGBA{}
+	}
+	var c [4]float64
+	switch {
+	case t <= g.stops[0].offset:
+		c = g.stops[0].c
+	case t >= g.stops[n-1].offset:
+		c = g.stops[n-1].c
+	default:
+		i := sort.Search(n, func(i int) bool { return g.stops[i].offset > t }) - 1
+		s0 := g.stops[i]
+		s1 := g.stops[i+1]
+		f := (t - s0.offset) / (s1.offset - s0.offset)
+		for k := range c {
+			c[k] = s0.c[k] + (s1.c[k]-s0.c[k])*f
+		}
+	}
+	return color.RGBA{uint8(c[0] + 0.5), uint8(c[1] + 0.5), uint8(c[2] + 0.5), uint8(c[3] + 0.5)}
+}
+
+// Color at the screen (x, y)
+func (g *Gradient) ColorAt(x int, y int) color.RGBA {
+	return g.colorAt(g.offset(float64(x), float64(y)))
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Masked PIXELARRAY drawing with run-length encoded masks
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"errors"
+	"image"
+)
+
+// Run of the unmasked pixels in a row
+type maskRun struct {
+	x int
+	n int
+}
+
+// MaskRuns is the run-length encoded mask of PIXELARRAY. Making it once
+// saves scanning the mask on every draw of the same image.
+type MaskRuns struct {
+	width  int
+	height int
+	rows   [][]maskRun
+}
+
+// Encode the mask of p
+func NewMaskRuns(p PIXELARRAY) *MaskRuns {
+	w := p.GetWidth()
+	h := p.GetHeight()
+	mask := p.GetMasks()
+	m := &MaskRuns{width: w, height: h, rows: make([][]maskRun, h)}
+	for y := 0; y < h; y++ {
+		row := mask[y*w : (y+1)*w]
+		for x := 0; x < w; {
+			if !row[x] {
+				x++
+				continue
+			}
+			n := 1
+			for x+n < w && row[x+n] {
+				n++
+			}
+			m.rows[y] = append(m.rows[y], maskRun{x, n})
+			x += n
+		}
+	}
+	return m
+}
+
+// True if all pixels are unmasked
+func (m *MaskRuns) IsOpaque() bool {
+	for _, row := range m.rows {
+		if len(row) != 1 || row[0].n != m.width {
+			return false
+		}
+	}
+	return true
+}
+
+// Draw the PIXELARRAY at (px, py), the masked pixels are not drawn
+// unlike PutPixelArray.
+// runs is the encoded mask of p, or nil to use the mask of p directly.
+// The unmasked spans are copied at once.
+func (wsd *WsDisplay) PutPixelArrayMasked(px int, py int, p PIXELARRAY, runs *MaskRuns) error {
+	if err := wsd.checkPixelArray(p); err != nil {
+		return err
+	}
+	w := p.GetWidth()
+	h := p.GetHeight()
+	if runs == nil {
+		wsd.putPixelArrayRectMasked(image.Pt(px, py), p, image.Rect(0, 0, w, h))
+		return nil
+	}
+	if runs.width != w || runs.height != h {
+		return errors.New("Unmatch MaskRuns size")
+	}
+	dst := image.Rect(px, py, px+w, py+h)
+	buf, bpp := pixelArrayBytes(p)
+	sx, _ := wsd.pixelSteps()
+	for _, c := range wsd.clipRects() {
+		r := dst.Intersect(c)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			row := (y - py) * w
+			for _, run := range runs.rows[y-py] {
+				x0 := px + run.x
+				x1 := x0 + run.n
+				if x0 >= r.Max.X {
+					break
+				}
+				if x0 < r.Min.X {
+					x0 = r.Min.X
+				}
+				if x1 > r.Max.X {
+					x1 = r.Max.X
+				}
+				if x0 >= x1 {
+					continue
+				}
+				i := row + x0 - px
+				copyBytes(wsd.addr, wsd.pixelOffset(x0, y), sx,
+					buf[i*bpp:(i+x1-x0)*bpp], bpp)
+			}
+		}
+	}
+	return nil
+}
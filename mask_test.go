@@ -0,0 +1,121 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests and benchmarks for the masked PutPixelArray
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// PixelArray of w x h, the pixels out of the circle in it are masked
+func testMaskedArray(tb testing.TB, wsd *WsDisplay, w int, h int) PIXELARRAY {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	r := w
+	if h < r {
+		r = h
+	}
+	r /= 2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x-w/2, y-h/2
+			if dx*dx+dy*dy <= r*r {
+				img.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), 200, 255})
+			}
+		}
+	}
+	p, err := wsd.NewPixelArray()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	p.StoreImage(img, wsd.GetRGBmask())
+	return p
+}
+
+func TestPutPixelArrayMasked(t *testing.T) {
+	for _, depth := range []int{16, 24, 32} {
+		bg := color.RGBA{0, 255, 0, 255}
+		want := newTestDisplay(t, 40, 30, depth)
+		want.FillBox(want.screenRect(), want.NewPixel(bg))
+		p := testMaskedArray(t, want, 24, 20)
+		mask := p.GetMasks()
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 24; x++ {
+				if mask[x+y*24] {
+					want.SetPixel(x-4, y+15, arrayPixel(p, x, y))
+				}
+			}
+		}
+		for _, runs := range []*MaskRuns{nil, NewMaskRuns(p)} {
+			wsd := newTestDisplay(t, 40, 30, depth)
+			wsd.FillBox(wsd.screenRect(), wsd.NewPixel(bg))
+			if err := wsd.PutPixelArrayMasked(-4, 15, p, runs); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(wsd.GetBuffer(), want.GetBuffer()) {
+				t.Errorf("depth %d runs %v: result differs", depth, runs != nil)
+			}
+			if c := wsd.pixelColor(wsd.GetPixel(0, 15)); c != wsd.pixelColor(wsd.NewPixel(bg)) {
+				t.Errorf("depth %d: masked pixel is drawn, %v", depth, c)
+			}
+		}
+		if NewMaskRuns(p).IsOpaque() {
+			t.Error("IsOpaque for the masked array")
+		}
+	}
+}
+
+func benchmarkPut(b *testing.B, put func(wsd *WsDisplay, p PIXELARRAY, runs *MaskRuns)) {
+	wsd := newTestDisplay(b, 1024, 768, 32)
+	p := testMaskedArray(b, wsd, 640, 480)
+	runs := NewMaskRuns(p)
+	b.SetBytes(640 * 480 * 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		put(wsd, p, runs)
+	}
+}
+
+func BenchmarkPutPixelArray(b *testing.B) {
+	benchmarkPut(b, func(wsd *WsDisplay, p PIXELARRAY, runs *MaskRuns) {
+		wsd.PutPixelArray(100, 100, p)
+	})
+}
+
+func BenchmarkPutPixelArrayMaskedRuns(b *testing.B) {
+	benchmarkPut(b, func(wsd *WsDisplay, p PIXELARRAY, runs *MaskRuns) {
+		wsd.PutPixelArrayMasked(100, 100, p, runs)
+	})
+}
+
+func BenchmarkPutPixelArrayMaskedScan(b *testing.B) {
+	benchmarkPut(b, func(wsd *WsDisplay, p PIXELARRAY, runs *MaskRuns) {
+		wsd.PutPixelArrayMasked(100, 100, p, nil)
+	})
+}
@@ -31,76 +31,78 @@ import (
 	"image/color"
 )
 
+// Draw the PIXELARRAY at (px, py). All pixels are copied and the mask is
+// ignored, it's the fastest way for opaque images. Use PutPixelArrayMasked
+// or PutPixelArrayRect to skip the masked pixels.
 func (wsd *WsDisplay) PutPixelArray(px int, py int, p PIXELARRAY) error {
-	screenW := int(wsd.GetWidth())
-	screenH := int(wsd.GetHeight())
-	w := p.GetWidth()
-	h := p.GetHeight()
-	if px+w < 0 || py+h < 0 || px >= screenW || py >= screenH {
-		// Nothing to do. All area is out of screen
-		return nil
-	}
-	startx := 0
-	starty := 0
-	endx := w
-	endy := h
-	if px < 0 {
-		startx = (0 - px)
-		endx = endx + px
-	}
-	if w+px > screenW {
-		endx = screenW - px
+	if err := wsd.checkPixelArray(p); err != nil {
+		return err
 	}
-	if py < 0 {
-		starty = (0 - py)
-		endy = endy + py
+	wsd.putPixelArrayRect(image.Pt(px, py), p,
+		image.Rect(0, 0, p.GetWidth(), p.GetHeight()))
+	return nil
+}
+
+// Draw the src area of p at dst, the masked pixels are not drawn.
+// src is clipped by p and the moved part of dst follows it.
+func (wsd *WsDisplay) PutPixelArrayRect(dst image.Point, p PIXELARRAY, src image.Rectangle) error {
+	if err := wsd.checkPixelArray(p); err != nil {
+		return err
 	}
-	if h+py > screenH {
-		endy = screenH - py
+	src = src.Canon()
+	r := src.Intersect(image.Rect(0, 0, p.GetWidth(), p.GetHeight()))
+	if r.Empty() {
+		return nil
 	}
-	s := int(wsd.GetPixelStride())
-	switch p.(type) {
-	case *PIXEL32ARRAY:
-		if wsd.GetDepth() != 32 {
-			err := errors.New("Unmatch PixelDepth")
-			return err
-		}
-		q := p.(*PIXEL32ARRAY)
-		pix := wsd.GetBufferAsPixel32()
-		for srcy := starty; srcy < endy; srcy++ {
-			copy(
-				pix[startx+px+(srcy+py)*s:startx+px+(srcy+py)*s+endx],
-				q.pix[startx+srcy*w:startx+srcy*w+endx])
-		}
-	case *PIXEL24ARRAY:
-		if wsd.GetDepth() != 24 {
-			err := errors.New("Unmatch PixelDepth")
-			return err
-		}
-		q := p.(*PIXEL24ARRAY)
-		pix := wsd.GetBufferAsPixel24()
-		for srcy := starty; srcy < endy; srcy++ {
-			copy(
-				pix[startx+px+(srcy+py)*s:startx+px+(srcy+py)*s+endx],
-				q.pix[startx+srcy*w:startx+srcy*w+endx])
-		}
-	case *PIXEL16ARRAY:
-		if wsd.GetDepth() != 16 {
-			err := errors.New("Unmatch PixelDepth")
-			return err
+	wsd.putPixelArrayRectMasked(dst.Add(r.Min.Sub(src.Min)), p, r)
+	return nil
+}
+
+// Copy the src area of p to dst on the screen, src must be in p.
+func (wsd *WsDisplay) putPixelArrayRect(dst image.Point, p PIXELARRAY, src image.Rectangle) {
+	w := p.GetWidth()
+	d := src.Sub(src.Min).Add(dst)
+	buf, bpp := pixelArrayBytes(p)
+	sx, _ := wsd.pixelSteps()
+	for _, c := range wsd.clipRects() {
+		r := d.Intersect(c)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := (r.Min.X - dst.X + src.Min.X + (y-dst.Y+src.Min.Y)*w) * bpp
+			copyBytes(wsd.addr, wsd.pixelOffset(r.Min.X, y), sx,
+				buf[i:i+r.Dx()*bpp], bpp)
 		}
-		q := p.(*PIXEL16ARRAY)
-		pix := wsd.GetBufferAsPixel16()
-		for srcy := starty; srcy < endy; srcy++ {
-			copy(
-				pix[startx+px+(srcy+py)*s:startx+px+(srcy+py)*s+endx],
-				q.pix[startx+srcy*w:startx+srcy*w+endx])
+	}
+}
+
+// Same as putPixelArrayRect, but the masked pixels are not drawn.
+// The runs of the unmasked pixels in a row are copied at once.
+func (wsd *WsDisplay) putPixelArrayRectMasked(dst image.Point, p PIXELARRAY, src image.Rectangle) {
+	w := p.GetWidth()
+	mask := p.GetMasks()
+	d := src.Sub(src.Min).Add(dst)
+	buf, bpp := pixelArrayBytes(p)
+	sx, _ := wsd.pixelSteps()
+	for _, c := range wsd.clipRects() {
+		r := d.Intersect(c)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := r.Min.X - dst.X + src.Min.X + (y-dst.Y+src.Min.Y)*w
+			for x := r.Min.X; x < r.Max.X; {
+				if !mask[i] {
+					x++
+					i++
+					continue
+				}
+				n := 1
+				for x+n < r.Max.X && mask[i+n] {
+					n++
+				}
+				copyBytes(wsd.addr, wsd.pixelOffset(x, y), sx,
+					buf[i*bpp:(i+n)*bpp], bpp)
+				x += n
+				i += n
+			}
 		}
-	default:
-		err := errors.New("Unsupported PixelType")
-		return err
 	}
-	return nil
 }
 
 func (wsd *WsDisplay) NewPixelArray() (p PIXELARRAY, err error) {
@@ -117,7 +119,39 @@ func (wsd *WsDisplay) NewPixelArray() (p PIXELARRAY, err error) {
 	return p, nil
 }
 
+// Create new w x h PIXELARRAY, all pixels are masked out
+func (wsd *WsDisplay) newPixelArraySize(w int, h int) (PIXELARRAY, error) {
+	p, err := wsd.NewPixelArray()
+	if err != nil {
+		return nil, err
+	}
+	pa := pixelarray{width: w, height: h, mask: make([]bool, w*h)}
+	switch q := p.(type) {
+	case *PIXEL32ARRAY:
+		q.pixelarray = pa
+		q.pix = make([]PIXEL32, w*h)
+	case *PIXEL24ARRAY:
+		q.pixelarray = pa
+		q.pix = make([]PIXEL24, w*h)
+	case *PIXEL16ARRAY:
+		q.pixelarray = pa
+		q.pix = make([]PIXEL16, w*h)
+	}
+	return p, nil
+}
+
+// Clear the screen, or the clip region if it is set
 func (wsd *WsDisplay) Clear() {
+	if wsd.clip != nil {
+		p := wsd.NewPixel(color.RGBA{0, 0, 0, 0})
+		if p == nil {
+			return
+		}
+		for _, c := range wsd.clipRects() {
+			wsd.FillBox(c, p)
+		}
+		return
+	}
 	switch wsd.GetDepth() {
 	case 32:
 		pix := wsd.GetBufferAsPixel32()
@@ -148,73 +182,125 @@ func (wsd *WsDisplay) NewPixel(c color.Color) (p PIXEL) {
 	default:
 		return nil
 	}
-	p.SetColor(c, wsd.GetRGBmask())
+	wsd.setPixelColor(p, c)
 	return
 }
 
 func (wsd *WsDisplay) SetPixel(px int, py int, p PIXEL) {
-	if px < 0 || py < 0 ||
-		px >= int(wsd.GetWidth()) || py >= int(wsd.GetHeight()) {
-		// Nothing to do. All area is out of screen
+	if !wsd.inClip(px, py) {
+		// Nothing to do. Out of screen or clip region
 		return
 	}
+	b := pixelBytes(p)
+	if len(b) != wsd.bytesPerPixel() {
+		return
+	}
+	off := wsd.pixelOffset(px, py)
+	copy(wsd.addr[off:off+len(b)], b)
+}
 
-	depth := int(wsd.GetDepth())
-	switch p.(type) {
-	case *PIXEL32:
-		if depth != 32 {
-			return
-		}
-		pix := wsd.GetBufferAsPixel32()
-		pix[px+py*int(wsd.GetPixelStride())] = *p.(*PIXEL32)
+// Fill the horizontal span [x0, x1) on the line y with the pixel
+func (wsd *WsDisplay) fillSpan(x0 int, x1 int, y int, p PIXEL) {
+	if wsd.clip == nil {
+		wsd.fillSpanIn(x0, x1, y, p, wsd.screenRect())
+		return
+	}
+	for _, c := range wsd.clip {
+		wsd.fillSpanIn(x0, x1, y, p, c.Intersect(wsd.screenRect()))
+	}
+}
 
-	case *PIXEL24:
-		if depth != 24 {
-			return
-		}
-		pix := wsd.GetBufferAsPixel24()
-		pix[px+py*int(wsd.GetPixelStride())] = *p.(*PIXEL24)
-	case *PIXEL16:
-		if depth != 16 {
-			return
-		}
-		pix := wsd.GetBufferAsPixel16()
-		pix[px+py*int(wsd.GetPixelStride())] = *p.(*PIXEL16)
+// Fill the span in the rectangle c, c must be in the screen
+func (wsd *WsDisplay) fillSpanIn(x0 int, x1 int, y int, p PIXEL, c image.Rectangle) {
+	if y < c.Min.Y || y >= c.Max.Y {
+		return
 	}
+	if x0 < c.Min.X {
+		x0 = c.Min.X
+	}
+	if x1 > c.Max.X {
+		x1 = c.Max.X
+	}
+	if x0 >= x1 {
+		return
+	}
+	b := pixelBytes(p)
+	if len(b) != wsd.bytesPerPixel() {
+		return
+	}
+	sx, _ := wsd.pixelSteps()
+	fillBytes(wsd.addr, wsd.pixelOffset(x0, y), sx, x1-x0, b)
+}
 
+// Get the pixel data at (px, py) in the framebuffer.
+// Returns nil if it's out of screen.
+func (wsd *WsDisplay) GetPixel(px int, py int) PIXEL {
+	if px < 0 || py < 0 ||
+		px >= int(wsd.GetWidth()) || py >= int(wsd.GetHeight()) {
+		return nil
+	}
+	switch wsd.GetDepth() {
+	case 32, 24, 16:
+		off := wsd.pixelOffset(px, py)
+		return newPixelFromBytes(wsd.addr[off : off+wsd.bytesPerPixel()])
+	}
+	return nil
 }
 
+// Rectangles are half-open in all operations, the area of rect is
+// [rect.Min.X, rect.Max.X) x [rect.Min.Y, rect.Max.Y) same as image.Rectangle.
+// DrawBox draws the outermost pixels of the area which FillBox fills.
 func (wsd *WsDisplay) DrawBox(rect image.Rectangle, p PIXEL) {
-	for x := rect.Min.X; x < rect.Max.X; x++ {
-		wsd.SetPixel(x, rect.Min.Y, p)
-		wsd.SetPixel(x, rect.Max.Y, p)
+	boxOutline(rect, func(x int, y int) {
+		wsd.SetPixel(x, y, p)
+	})
+}
+
+// Points of the outline of [rect.Min, rect.Max), each point once
+func boxOutline(rect image.Rectangle, plot func(x int, y int)) {
+	rect = rect.Canon()
+	if rect.Empty() {
+		return
 	}
-	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		wsd.SetPixel(rect.Min.X, y, p)
-		wsd.SetPixel(rect.Max.X, y, p)
+	right := rect.Max.X - 1
+	bottom := rect.Max.Y - 1
+	for x := rect.Min.X; x <= right; x++ {
+		plot(x, rect.Min.Y)
+		if bottom != rect.Min.Y {
+			plot(x, bottom)
+		}
+	}
+	for y := rect.Min.Y + 1; y < bottom; y++ {
+		plot(rect.Min.X, y)
+		if right != rect.Min.X {
+			plot(right, y)
+		}
 	}
 }
+
+// Fill the area [rect.Min, rect.Max)
 func (wsd *WsDisplay) FillBox(rect image.Rectangle, p PIXEL) {
+	boxSpans(rect, func(x0 int, x1 int, y int) {
+		wsd.fillSpan(x0, x1, y, p)
+	})
+}
+
+// Spans of the area [rect.Min, rect.Max)
+func boxSpans(rect image.Rectangle, span func(x0 int, x1 int, y int)) {
+	rect = rect.Canon()
 	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		for x := rect.Min.X; x < rect.Max.X; x++ {
-			wsd.SetPixel(x, y, p)
-		}
+		span(rect.Min.X, rect.Max.X, y)
 	}
 }
 
-func (wsd *WsDisplay) DrawCircle(px int, py int, r int, p PIXEL) {
+// Points of the circle in the octant x >= y (midpoint algorithm),
+// x and y are the offsets from the center.
+func circleOctant(r int, plot func(x int, y int)) {
 	x := r
 	y := 0
 	d := r*-2 + 3
 	for x >= y {
-		wsd.SetPixel(px+x, py+y, p)
-		wsd.SetPixel(px-x, py+y, p)
-		wsd.SetPixel(px+x, py-y, p)
-		wsd.SetPixel(px-x, py-y, p)
-		wsd.SetPixel(px+y, py+x, p)
-		wsd.SetPixel(px-y, py+x, p)
-		wsd.SetPixel(px+y, py-x, p)
-		wsd.SetPixel(px-y, py-x, p)
+		plot(x, y)
 		if d >= 0 {
 			x--
 			d -= x * 4
@@ -223,28 +309,59 @@ func (wsd *WsDisplay) DrawCircle(px int, py int, r int, p PIXEL) {
 		d += y*4 + 2
 	}
 }
+
+// Draw circle, center (px, py) and radius r. The circle is in the
+// area image.Rect(px-r, py-r, px+r+1, py+r+1), same as FillCircle.
+func (wsd *WsDisplay) DrawCircle(px int, py int, r int, p PIXEL) {
+	if r < 0 {
+		return
+	}
+	circleOctant(r, func(x int, y int) {
+		wsd.plot4(px, py, x, y, p)
+		if x != y {
+			wsd.plot4(px, py, y, x, p)
+		}
+	})
+}
+
+// Fill circle, center (px, py) and radius r. The filled pixels are
+// the inside of DrawCircle and DrawCircle itself.
 func (wsd *WsDisplay) FillCircle(px int, py int, r int, p PIXEL) {
-	x := r
-	y := 0
-	d := r*-2 + 3
-	for x >= y {
-		for dx := px - x; dx < px+x; dx++ {
-			wsd.SetPixel(dx, py+y, p)
-			wsd.SetPixel(dx, py-y, p)
+	circleSpans(px, py, r, func(x0 int, x1 int, y int) {
+		wsd.fillSpan(x0, x1, y, p)
+	})
+}
+
+// Spans of the filled circle
+func circleSpans(px int, py int, r int, span func(x0 int, x1 int, y int)) {
+	if r < 0 {
+		return
+	}
+	ext := make([]int, r+1)
+	circleOctant(r, func(x int, y int) {
+		if x > ext[y] {
+			ext[y] = x
 		}
-		for dx := px - y; dx < px+y; dx++ {
-			wsd.SetPixel(dx, py+x, p)
-			wsd.SetPixel(dx, py-x, p)
+		if y > ext[x] {
+			ext[x] = y
 		}
-		if d >= 0 {
-			x--
-			d -= x * 4
+	})
+	for y, x := range ext {
+		span(px-x, px+x+1, py+y)
+		if y != 0 {
+			span(px-x, px+x+1, py-y)
 		}
-		y++
-		d += y*4 + 2
 	}
 }
+
 func (wsd *WsDisplay) DrawLine(p0 image.Point, p1 image.Point, p PIXEL) {
+	linePoints(p0, p1, func(x int, y int) {
+		wsd.SetPixel(x, y, p)
+	})
+}
+
+// Points of the line from p0 to p1 (Bresenham's algorithm)
+func linePoints(p0 image.Point, p1 image.Point, plot func(x int, y int)) {
 	sx := -1
 	sy := -1
 	dx := p0.X - p1.X
@@ -263,7 +380,7 @@ func (wsd *WsDisplay) DrawLine(p0 image.Point, p1 image.Point, p PIXEL) {
 
 	er := dx - dy
 	for {
-		wsd.SetPixel(px, py, p)
+		plot(px, py)
 		if px == p1.X && py == p1.Y {
 			break
 		}
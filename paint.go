@@ -0,0 +1,255 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Paint sources for the fill operations
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+)
+
+// Paint is the color source of the fill operations.
+// ColorAt returns the alpha-premultiplied color at the screen (x, y).
+type Paint interface {
+	ColorAt(x int, y int) color.RGBA
+}
+
+// 4x4 ordered dither matrix
+var bayer4 = [4][4]uint32{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// Check the paint wants the dithering
+func paintDither(paint Paint) bool {
+	if g, ok := paint.(*Gradient); ok {
+		return g.dither
+	}
+	return false
+}
+
+// Add the ordered dither to the channel v quantized to size bits
+func ditherChannel(v uint8, size uint32, x int, y int) uint8 {
+	if size >= 8 || size == 0 {
+		return v
+	}
+	step := uint32(1) << (8 - size)
+	d := uint32(v) + (bayer4[y&3][x&3]*2+1)*step/32
+	if d > 255 {
+		d = 255
+	}
+	return uint8(d)
+}
+
+// Dither the opaque color c at (x, y) for the display
+func (wsd *WsDisplay) ditherColor(c color.RGBA, x int, y int) color.RGBA {
+	m := wsd.GetRGBmask()
+	c.R = ditherChannel(c.R, m.Red_size, x, y)
+	c.G = ditherChannel(c.G, m.Green_size, x, y)
+	c.B = ditherChannel(c.B, m.Blue_size, x, y)
+	return c
+}
+
+// Draw the premultiplied color c at (x, y) with coverage a (0-255).
+// q is the work pixel for the display.
+func (wsd *WsDisplay) paintPixel(x int, y int, c color.RGBA, a uint32, dither bool, q PIXEL) {
+	if a < 255 {
+		c.R = uint8(uint32(c.R) * a / 255)
+		c.G = uint8(uint32(c.G) * a / 255)
+		c.B = uint8(uint32(c.B) * a / 255)
+		c.A = uint8(uint32(c.A) * a / 255)
+	}
+	switch c.A {
+	case 0:
+		return
+	case 255:
+		if dither {
+			c = wsd.ditherColor(c, x, y)
+		}
+		wsd.setPixelColor(q, c)
+		wsd.SetPixel(x, y, q)
+	default:
+		// blendPixel takes the straight color
+		a := uint32(c.A)
+		wsd.blendPixel(x, y, color.RGBA{
+			R: uint8(uint32(c.R) * 255 / a),
+			G: uint8(uint32(c.G) * 255 / a),
+			B: uint8(uint32(c.B) * 255 / a),
+			A: 255,
+		}, a)
+	}
+}
+
+// Fill the span [x0, x1) on the line y with the paint
+func (wsd *WsDisplay) paintSpan(x0 int, x1 int, y int, paint Paint) {
+	switch pt := paint.(type) {
+	case *solidPaint:
+		if pt.c.A == 255 {
+			wsd.fillSpan(x0, x1, y, pt.p)
+			return
+		}
+	case *pixelArrayPaint:
+		wsd.copySpan(x0, x1, y, pt)
+		return
+	}
+	q := wsd.NewPixel(color.Black)
+	if q == nil {
+		return
+	}
+	dither := paintDither(paint)
+	for _, c := range wsd.clipRects() {
+		if y < c.Min.Y || y >= c.Max.Y {
+			continue
+		}
+		s := x0
+		if s < c.Min.X {
+			s = c.Min.X
+		}
+		for x := s; x < x1 && x < c.Max.X; x++ {
+			wsd.paintPixel(x, y, paint.ColorAt(x, y), 255, dither, q)
+		}
+	}
+}
+
+// Copy the raw pixels of the PIXELARRAY paint to the span
+func (wsd *WsDisplay) copySpan(x0 int, x1 int, y int, pt *pixelArrayPaint) {
+	buf, bpp := pixelArrayBytes(pt.p)
+	sx, _ := wsd.pixelSteps()
+	for _, c := range wsd.clipRects() {
+		if y < c.Min.Y || y >= c.Max.Y {
+			continue
+		}
+		s := x0
+		if s < c.Min.X {
+			s = c.Min.X
+		}
+		off := wsd.pixelOffset(s, y)
+		for x := s; x < x1 && x < c.Max.X; x++ {
+			if i := pt.index(x, y); i >= 0 {
+				copy(wsd.addr[off:off+bpp], buf[i*bpp:(i+1)*bpp])
+			}
+			off += sx
+		}
+	}
+}
+
+// Fill the polygons with the paint
+func (wsd *WsDisplay) paintPolygons(polys [][]fpoint, rule FillRule, paint Paint) {
+	rasterize(polys, rule, wsd.clipBounds(), func(x0 int, x1 int, y int) {
+		wsd.paintSpan(x0, x1, y, paint)
+	})
+}
+
+// Fill the polygons with the paint, with anti-aliasing
+func (wsd *WsDisplay) paintPolygonsAA(polys [][]fpoint, rule FillRule, paint Paint) {
+	q := wsd.NewPixel(color.Black)
+	if q == nil {
+		return
+	}
+	dither := paintDither(paint)
+	rasterizeAA(polys, rule, wsd.clipBounds(), func(x0 int, y int, cov []float64) {
+		run := -1 // start of fully covered pixels
+		for i, f := range cov {
+			if f >= 0.999 {
+				if run < 0 {
+					run = i
+				}
+				continue
+			}
+			if run >= 0 {
+				wsd.paintSpan(x0+run, x0+i, y, paint)
+				run = -1
+			}
+			x := x0 + i
+			if wsd.inClip(x, y) {
+				wsd.paintPixel(x, y, paint.ColorAt(x, y), coverage(f), dither, q)
+			}
+		}
+		if run >= 0 {
+			wsd.paintSpan(x0+run, x0+len(cov), y, paint)
+		}
+	})
+}
+
+// Fill the area [rect.Min, rect.Max) with the paint
+func (wsd *WsDisplay) FillBoxPaint(rect image.Rectangle, paint Paint) {
+	boxSpans(rect, func(x0 int, x1 int, y int) {
+		wsd.paintSpan(x0, x1, y, paint)
+	})
+}
+
+// Fill circle with the paint, the area is same as FillCircle
+func (wsd *WsDisplay) FillCirclePaint(px int, py int, r int, paint Paint) {
+	circleSpans(px, py, r, func(x0 int, x1 int, y int) {
+		wsd.paintSpan(x0, x1, y, paint)
+	})
+}
+
+// Fill rounded rectangle with the paint, the area is same as FillRoundRect
+func (wsd *WsDisplay) FillRoundRectPaint(rect image.Rectangle, r int, paint Paint) {
+	roundRectSpans(rect, r, func(x0 int, x1 int, y int) {
+		wsd.paintSpan(x0, x1, y, paint)
+	})
+}
+
+// Fill the polygon with the rule and the paint
+func (wsd *WsDisplay) FillPolygonPaint(pts []image.Point, rule FillRule, paint Paint) {
+	wsd.paintPolygons([][]fpoint{toFpoints(pts)}, rule, paint)
+}
+
+// Fill the path with the rule and the paint
+func (wsd *WsDisplay) FillPathPaint(path *Path, rule FillRule, paint Paint) {
+	polys, _ := path.flatten()
+	wsd.paintPolygons(polys, rule, paint)
+}
+
+// Fill ellipse with the paint, the area is same as FillEllipse
+func (wsd *WsDisplay) FillEllipsePaint(px int, py int, rx int, ry int, paint Paint) {
+	ellipseSpans(px, py, rx, ry, func(x0 int, x1 int, y int) {
+		wsd.paintSpan(x0, x1, y, paint)
+	})
+}
+
+// Fill pie slice with the paint, the area is same as FillPie
+func (wsd *WsDisplay) FillPiePaint(px int, py int, rx int, ry int, start float64, sweep float64, paint Paint) {
+	pieSpans(px, py, rx, ry, start, sweep, func(x0 int, x1 int, y int) {
+		wsd.paintSpan(x0, x1, y, paint)
+	})
+}
+
+// Fill the polygon with the rule and the paint, with anti-aliasing
+func (wsd *WsDisplay) FillPolygonAAPaint(pts []image.Point, rule FillRule, paint Paint) {
+	wsd.paintPolygonsAA([][]fpoint{toFpoints(pts)}, rule, paint)
+}
+
+// Fill the path with the rule and the paint, with anti-aliasing
+func (wsd *WsDisplay) FillPathAAPaint(path *Path, rule FillRule, paint Paint) {
+	polys, _ := path.flatten()
+	wsd.paintPolygonsAA(polys, rule, paint)
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Path builder and polygon filling
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"math"
+)
+
+// Flattening tolerance of the curves in pixels
+const flatness = 0.1
+
+type pathOp int
+
+const (
+	pathMove pathOp = iota
+	pathLine
+	pathQuad
+	pathCubic
+	pathClose
+)
+
+type pathCmd struct {
+	op  pathOp
+	pts [3]fpoint
+}
+
+// Path is a sequence of the drawing commands.
+// The coordinates are same as the pixel coordinates of the screen,
+// an integer point is the center of the pixel.
+type Path struct {
+	cmds  []pathCmd
+	start fpoint // start point of current sub path
+	cur   fpoint // current point
+	open  bool   // sub path is started
+}
+
+// Create new empty path
+func NewPath() *Path {
+	return new(Path)
+}
+
+// Start new sub path at (x, y)
+func (p *Path) MoveTo(x float64, y float64) {
+	pt := fpoint{x, y}
+	p.cmds = append(p.cmds, pathCmd{op: pathMove, pts: [3]fpoint{pt}})
+	p.start = pt
+	p.cur = pt
+	p.open = true
+}
+
+// Line from the current point to (x, y)
+func (p *Path) LineTo(x float64, y float64) {
+	if !p.open {
+		p.MoveTo(x, y)
+		return
+	}
+	pt := fpoint{x, y}
+	p.cmds = append(p.cmds, pathCmd{op: pathLine, pts: [3]fpoint{pt}})
+	p.cur = pt
+}
+
+// Quadratic Bezier curve with the control point (cx, cy) to (x, y)
+func (p *Path) QuadTo(cx float64, cy float64, x float64, y float64) {
+	if !p.open {
+		p.MoveTo(cx, cy)
+	}
+	pt := fpoint{x, y}
+	p.cmds = append(p.cmds, pathCmd{op: pathQuad, pts: [3]fpoint{{cx, cy}, pt}})
+	p.cur = pt
+}
+
+// Cubic Bezier curve with the control points (c1x, c1y), (c2x, c2y)
+// to (x, y)
+func (p *Path) CubicTo(c1x float64, c1y float64, c2x float64, c2y float64, x float64, y float64) {
+	if !p.open {
+		p.MoveTo(c1x, c1y)
+	}
+	pt := fpoint{x, y}
+	p.cmds = append(p.cmds,
+		pathCmd{op: pathCubic, pts: [3]fpoint{{c1x, c1y}, {c2x, c2y}, pt}})
+	p.cur = pt
+}
+
+// Elliptical arc, center (cx, cy) and radius (rx, ry), from the angle
+// start to start+sweep in degrees. The angle is clockwise on the screen
+// from the positive x axis. If the path has the current point, a line
+// is added to the start of the arc.
+func (p *Path) Arc(cx float64, cy float64, rx float64, ry float64, start float64, sweep float64) {
+	a0 := start * math.Pi / 180
+	da := sweep * math.Pi / 180
+	x0 := cx + rx*math.Cos(a0)
+	y0 := cy + ry*math.Sin(a0)
+	if p.open {
+		p.LineTo(x0, y0)
+	} else {
+		p.MoveTo(x0, y0)
+	}
+
+	// split into the pieces of 90 degrees or less
+	n := int(math.Ceil(math.Abs(da) / (math.Pi / 2)))
+	if n == 0 {
+		return
+	}
+	step := da / float64(n)
+	k := 4.0 / 3.0 * math.Tan(step/4)
+	for i := 0; i < n; i++ {
+		t0 := a0 + step*float64(i)
+		t1 := t0 + step
+		cos0, sin0 := math.Cos(t0), math.Sin(t0)
+		cos1, sin1 := math.Cos(t1), math.Sin(t1)
+		p.CubicTo(
+			cx+rx*(cos0-k*sin0), cy+ry*(sin0+k*cos0),
+			cx+rx*(cos1+k*sin1), cy+ry*(sin1-k*cos1),
+			cx+rx*cos1, cy+ry*sin1)
+	}
+}
+
+// Close current sub path
+func (p *Path) Close() {
+	if !p.open {
+		return
+	}
+	p.cmds = append(p.cmds, pathCmd{op: pathClose})
+	p.cur = p.start
+	p.open = false
+}
+
+// Copy of the path transformed by m
+func (p *Path) Transform(m Affine) *Path {
+	apply := func(pt fpoint) fpoint {
+		x, y := m.Apply(pt.x, pt.y)
+		return fpoint{x, y}
+	}
+	q := &Path{
+		cmds:  make([]pathCmd, len(p.cmds)),
+		start: apply(p.start),
+		cur:   apply(p.cur),
+		open:  p.open,
+	}
+	for i, cmd := range p.cmds {
+		for j := range cmd.pts {
+			cmd.pts[j] = apply(cmd.pts[j])
+		}
+		q.cmds[i] = cmd
+	}
+	return q
+}
+
+// Number of segments to flatten the curve with the second difference d
+func curveSegments(d float64) int {
+	n := int(math.Ceil(math.Sqrt(d / flatness)))
+	if n < 1 {
+		n = 1
+	}
+	if n > 1000 {
+		n = 1000
+	}
+	return n
+}
+
+// Convert the path to polylines in sub-pixel coordinates.
+// closed[i] reports the polyline i is closed by Close().
+func (p *Path) flatten() (polys [][]fpoint, closed []bool) {
+	var cur []fpoint
+	var last fpoint
+	end := func(c bool) {
+		if len(cur) > 0 {
+			polys = append(polys, cur)
+			closed = append(closed, c)
+		}
+		cur = nil
+	}
+	for _, cmd := range p.cmds {
+		switch cmd.op {
+		case pathMove:
+			end(false)
+			last = cmd.pts[0]
+			cur = []fpoint{last}
+		case pathLine:
+			last = cmd.pts[0]
+			cur = append(cur, last)
+		case pathQuad:
+			p0, p1, p2 := last, cmd.pts[0], cmd.pts[1]
+			d := math.Hypot(p0.x-2*p1.x+p2.x, p0.y-2*p1.y+p2.y) / 4
+			n := curveSegments(d)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				cur = append(cur, fpoint{
+					u*u*p0.x + 2*u*t*p1.x + t*t*p2.x,
+					u*u*p0.y + 2*u*t*p1.y + t*t*p2.y,
+				})
+			}
+			last = p2
+		case pathCubic:
+			p0, p1, p2, p3 := last, cmd.pts[0], cmd.pts[1], cmd.pts[2]
+			d := math.Max(
+				math.Hypot(p0.x-2*p1.x+p2.x, p0.y-2*p1.y+p2.y),
+				math.Hypot(p1.x-2*p2.x+p3.x, p1.y-2*p2.y+p3.y)) * 3 / 4
+			n := curveSegments(d)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				cur = append(cur, fpoint{
+					u*u*u*p0.x + 3*u*u*t*p1.x + 3*u*t*t*p2.x + t*t*t*p3.x,
+					u*u*u*p0.y + 3*u*u*t*p1.y + 3*u*t*t*p2.y + t*t*t*p3.y,
+				})
+			}
+			last = p3
+		case pathClose:
+			if len(cur) > 0 {
+				last = cur[0]
+			}
+			end(true)
+		}
+	}
+	end(false)
+
+	// move to the center of the pixels
+	for _, poly := range polys {
+		for i := range poly {
+			poly[i].x += 0.5
+			poly[i].y += 0.5
+		}
+	}
+	return
+}
+
+// Fill the polygon with the rule
+func (wsd *WsDisplay) FillPolygon(pts []image.Point, rule FillRule, p PIXEL) {
+	wsd.fillPolygons([][]fpoint{toFpoints(pts)}, rule, p)
+}
+
+// Fill the polygon with the rule, with anti-aliasing
+func (wsd *WsDisplay) FillPolygonAA(pts []image.Point, rule FillRule, p PIXEL) {
+	wsd.fillPolygonsAA([][]fpoint{toFpoints(pts)}, rule, p)
+}
+
+// Fill the path with the rule, all sub paths are closed implicitly
+func (wsd *WsDisplay) FillPath(path *Path, rule FillRule, p PIXEL) {
+	polys, _ := path.flatten()
+	wsd.fillPolygons(polys, rule, p)
+}
+
+// Fill the path with the rule, with anti-aliasing
+func (wsd *WsDisplay) FillPathAA(path *Path, rule FillRule, p PIXEL) {
+	polys, _ := path.flatten()
+	wsd.fillPolygonsAA(polys, rule, p)
+}
+
+// Stroke the path with the style
+func (wsd *WsDisplay) StrokePath(path *Path, style LineStyle, p PIXEL) {
+	polys, closed := path.flatten()
+	var strokes [][]fpoint
+	for i, poly := range polys {
+		strokes = append(strokes, strokePolygons(poly, closed[i], style)...)
+	}
+	wsd.fillPolygons(strokes, NonZero, p)
+}
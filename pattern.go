@@ -0,0 +1,187 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Solid, pattern and image paints
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"image/color"
+)
+
+// How the image paint is repeated
+type RepeatMode int
+
+const (
+	NoRepeat RepeatMode = iota // transparent out of the image
+	Repeat                     // tile in both directions
+	RepeatX                    // tile horizontally only
+	RepeatY                    // tile vertically only
+	Reflect                    // tile in both directions, mirrored alternately
+)
+
+// Map the coordinate v to 0..n-1, false if it's out of the image
+func repeatCoord(v int, n int, repeat bool, reflect bool) (int, bool) {
+	if n <= 0 {
+		return 0, false
+	}
+	if v >= 0 && v < n {
+		return v, true
+	}
+	if !repeat {
+		return 0, false
+	}
+	if reflect {
+		m := v % (2 * n)
+		if m < 0 {
+			m += 2 * n
+		}
+		if m >= n {
+			m = 2*n - 1 - m
+		}
+		return m, true
+	}
+	m := v % n
+	if m < 0 {
+		m += n
+	}
+	return m, true
+}
+
+// Map the screen (x, y) to the image of w x h placed at off
+func repeatPoint(x int, y int, off image.Point, w int, h int, mode RepeatMode) (int, int, bool) {
+	rx := mode == Repeat || mode == RepeatX || mode == Reflect
+	ry := mode == Repeat || mode == RepeatY || mode == Reflect
+	sx, okx := repeatCoord(x-off.X, w, rx, mode == Reflect)
+	sy, oky := repeatCoord(y-off.Y, h, ry, mode == Reflect)
+	return sx, sy, okx && oky
+}
+
+// Paint of the single color
+type solidPaint struct {
+	p PIXEL
+	c color.RGBA
+}
+
+// Create the paint of the color c, same as the pixel of it
+func (wsd *WsDisplay) NewSolidPaint(c color.Color) Paint {
+	return &solidPaint{wsd.NewPixel(c), color.RGBAModel.Convert(c).(color.RGBA)}
+}
+
+func (s *solidPaint) ColorAt(x int, y int) color.RGBA {
+	return s.c
+}
+
+// Paint of the 8x8 two color pattern
+type patternPaint struct {
+	bits [8]uint8
+	off  image.Point
+	fg   color.RGBA
+	bg   color.RGBA
+}
+
+// Create the paint of the 8x8 pattern repeated from off. The bit 7 of
+// bits[y] is the left most pixel. The set bits are fg, others are bg,
+// bg can be nil for transparent.
+func NewPatternPaint(bits [8]uint8, off image.Point, fg color.Color, bg color.Color) Paint {
+	p := &patternPaint{bits: bits, off: off}
+	p.fg = color.RGBAModel.Convert(fg).(color.RGBA)
+	if bg != nil {
+		p.bg = color.RGBAModel.Convert(bg).(color.RGBA)
+	}
+	return p
+}
+
+func (p *patternPaint) ColorAt(x int, y int) color.RGBA {
+	sx, sy, _ := repeatPoint(x, y, p.off, 8, 8, Repeat)
+	if p.bits[sy]&(0x80>>uint(sx)) != 0 {
+		return p.fg
+	}
+	return p.bg
+}
+
+// Paint of the image
+type imagePaint struct {
+	img  *image.RGBA
+	off  image.Point
+	mode RepeatMode
+}
+
+// Create the paint of the image placed at off on the screen
+func NewImagePaint(img image.Image, off image.Point, mode RepeatMode) Paint {
+	return &imagePaint{img: imageToRGBA(img), off: off, mode: mode}
+}
+
+func (p *imagePaint) ColorAt(x int, y int) color.RGBA {
+	sx, sy, ok := repeatPoint(x, y, p.off, p.img.Rect.Dx(), p.img.Rect.Dy(), p.mode)
+	if !ok {
+		return color.RGBA{}
+	}
+	return p.img.RGBAAt(sx, sy)
+}
+
+// Paint of the PIXELARRAY, the pixels are copied without conversion
+type pixelArrayPaint struct {
+	p       PIXELARRAY
+	rgbmask RGBmask
+	order   ByteOrder
+	off     image.Point
+	mode    RepeatMode
+}
+
+// Create the paint of the PIXELARRAY placed at off on the screen.
+// The masked pixels are transparent.
+func (wsd *WsDisplay) NewPixelArrayPaint(p PIXELARRAY, off image.Point, mode RepeatMode) (Paint, error) {
+	if err := wsd.checkPixelArray(p); err != nil {
+		return nil, err
+	}
+	return &pixelArrayPaint{p, wsd.GetRGBmask(), wsd.GetByteOrder(), off, mode}, nil
+}
+
+// Index of the pixel in the array for the screen (x, y), -1 if none
+func (p *pixelArrayPaint) index(x int, y int) int {
+	w := p.p.GetWidth()
+	sx, sy, ok := repeatPoint(x, y, p.off, w, p.p.GetHeight(), p.mode)
+	if !ok {
+		return -1
+	}
+	i := sx + sy*w
+	if !p.p.GetMasks()[i] {
+		return -1
+	}
+	return i
+}
+
+func (p *pixelArrayPaint) ColorAt(x int, y int) color.RGBA {
+	i := p.index(x, y)
+	if i < 0 {
+		return color.RGBA{}
+	}
+	w := p.p.GetWidth()
+	c := pixelColor(arrayPixel(p.p, i%w, i/w), p.rgbmask, p.order)
+	c.A = 255
+	return c
+}
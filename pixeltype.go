@@ -39,6 +39,88 @@ type PIXEL interface {
 	SetColor(c color.Color, rgbmask RGBmask)
 }
 
+// Expand v of size bits to 8bit color component
+func expandColor(v uint32, size uint32) uint8 {
+	if size == 0 {
+		return 0
+	}
+	max := uint32(1)<<size - 1
+	return uint8((v & max) * 255 / max)
+}
+
+// Get color.RGBA from raw pixel data with specified mask
+func decodeColor(d uint32, rgbmask RGBmask) color.RGBA {
+	c := color.RGBA{
+		R: expandColor(d>>rgbmask.Red_offset, rgbmask.Red_size),
+		G: expandColor(d>>rgbmask.Green_offset, rgbmask.Green_size),
+		B: expandColor(d>>rgbmask.Blue_offset, rgbmask.Blue_size),
+		A: 255,
+	}
+	if rgbmask.Alpha_size > 0 {
+		c.A = expandColor(d>>rgbmask.Alpha_offset, rgbmask.Alpha_size)
+	}
+	return c
+}
+
+func hostBigEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 0
+}
+
+// Get the byte order, HostOrder is resolved to the order of the CPU
+func (o ByteOrder) resolve() ByteOrder {
+	if o != HostOrder {
+		return o
+	}
+	if hostBigEndian() {
+		return BigEndian
+	}
+	return LittleEndian
+}
+
+// Store the raw pixel value d to b in the byte order
+func putPixelValue(b []byte, d uint32, order ByteOrder) {
+	big := order.resolve() == BigEndian
+	for i := range b {
+		if big {
+			b[i] = uint8(d >> (8 * uint(len(b)-1-i)))
+		} else {
+			b[i] = uint8(d >> (8 * uint(i)))
+		}
+	}
+}
+
+// Get the raw pixel value from b in the byte order
+func getPixelValue(b []byte, order ByteOrder) (d uint32) {
+	big := order.resolve() == BigEndian
+	for i := range b {
+		if big {
+			d = d<<8 | uint32(b[i])
+		} else {
+			d |= uint32(b[i]) << (8 * uint(i))
+		}
+	}
+	return
+}
+
+// Whether the pixels in the byte order are swapped from the CPU order
+func (o ByteOrder) swapped() bool {
+	return o.resolve() != HostOrder.resolve()
+}
+
+// Reverse the bytes of each bpp bytes pixel in b, it converts the pixels
+// between little and big endian
+func swapPixelBytes(b []byte, bpp int) {
+	if bpp < 2 {
+		return
+	}
+	for i := 0; i+bpp <= len(b); i += bpp {
+		for j, k := i, i+bpp-1; j < k; j, k = j+1, k-1 {
+			b[j], b[k] = b[k], b[j]
+		}
+	}
+}
+
 // 32bit per pixel, ex RGBA(8:8:8:8)
 type PIXEL32 [4]uint8
 
@@ -66,9 +148,12 @@ func (p *PIXEL32) SetColor(c color.Color, rgbmask RGBmask) {
 	if rgbmask.Alpha_size > 0 {
 		d |= (a >> (8 - rgbmask.Alpha_size)) << rgbmask.Alpha_offset
 	}
-	for i := range p {
-		p[i] = (*PIXEL32)(unsafe.Pointer(&d))[i]
-	}
+	putPixelValue(p[:], d, HostOrder)
+}
+
+// Get 32bit-color data as color.RGBA
+func (p *PIXEL32) GetColor(rgbmask RGBmask) color.RGBA {
+	return decodeColor(getPixelValue(p[:], HostOrder), rgbmask)
 }
 
 // 24bit per pixel, ex RGB(8:8:8)
@@ -102,15 +187,38 @@ func (p *PIXEL24) SetColor(c color.Color, rgbmask RGBmask) {
 		d |= (a >> (8 - rgbmask.Alpha_size)) << rgbmask.Alpha_offset
 		m |= (255 >> (8 - rgbmask.Alpha_size)) << rgbmask.Alpha_offset
 	}
-	mp := (*PIXEL24)(unsafe.Pointer(&m))
+	var dw, mw [4]uint8
+	putPixelValue(dw[:], d, HostOrder)
+	putPixelValue(mw[:], uint32(m), HostOrder)
 	// convert to uint8 data in PIXEL
 	j := 0
-	for i := 0; i < len(mp); i++ {
-		if mp[i] != 0 {
-			p[j] = (*PIXEL24)(unsafe.Pointer(&d))[i]
+	for i := 0; i < len(mw) && j < len(p); i++ {
+		if mw[i] != 0 {
+			p[j] = dw[i]
+			j++
+		}
+	}
+}
+
+// Get 24bit-color data as color.RGBA
+func (p *PIXEL24) GetColor(rgbmask RGBmask) color.RGBA {
+	m := (255>>(8-rgbmask.Red_size))<<rgbmask.Red_offset |
+		(255>>(8-rgbmask.Green_size))<<rgbmask.Green_offset |
+		(255>>(8-rgbmask.Blue_size))<<rgbmask.Blue_offset
+	if rgbmask.Alpha_size > 0 {
+		m |= (255 >> (8 - rgbmask.Alpha_size)) << rgbmask.Alpha_offset
+	}
+	var dw, mw [4]uint8
+	putPixelValue(mw[:], uint32(m), HostOrder)
+	// restore uint32 data from the valid bytes in PIXEL
+	j := 0
+	for i := 0; i < len(mw) && j < len(p); i++ {
+		if mw[i] != 0 {
+			dw[i] = p[j]
 			j++
 		}
 	}
+	return decodeColor(getPixelValue(dw[:], HostOrder), rgbmask)
 }
 
 // 16bit per pixel, ex RGB(5:6:6) or YUV(4:2:2)
@@ -139,9 +247,12 @@ func (p *PIXEL16) SetColor(c color.Color, rgbmask RGBmask) {
 	//
 	// convert to int8 data in PIXEL
 	//
-	for i := range p {
-		p[i] = (*PIXEL16)(unsafe.Pointer(&d))[i]
-	}
+	putPixelValue(p[:], d, HostOrder)
+}
+
+// Get 16bit-color data as color.RGBA
+func (p *PIXEL16) GetColor(rgbmask RGBmask) color.RGBA {
+	return decodeColor(getPixelValue(p[:], HostOrder), rgbmask)
 }
 
 // 8bit per pixel, ex Gray8 or Color Indexed
@@ -156,6 +267,75 @@ func (p *PIXEL8) SetColor(c color.Color, mask RGBmask) {
 	p[0] = uint8((r*299 + g*587 + b*114) / 1000)
 }
 
+// Get 8bit gray data as color.RGBA
+func (p *PIXEL8) GetColor(mask RGBmask) color.RGBA {
+	return color.RGBA{p[0], p[0], p[0], 255}
+}
+
+// Get the color of the pixel in the byte order, zero for the unknown
+// pixel type
+func pixelColor(p PIXEL, rgbmask RGBmask, order ByteOrder) color.RGBA {
+	if q, ok := p.(*PIXEL8); ok {
+		return q.GetColor(rgbmask)
+	}
+	c, _ := bytesColor(pixelBytes(p), rgbmask, order)
+	return c
+}
+
+// Set the color to the pixel in the byte order
+func setPixelColor(p PIXEL, c color.Color, rgbmask RGBmask, order ByteOrder) {
+	p.SetColor(c, rgbmask)
+	if order.swapped() {
+		b := pixelBytes(p)
+		swapPixelBytes(b, len(b))
+	}
+}
+
+// Decode the raw pixel b in the byte order, without allocating a PIXEL
+func bytesColor(b []byte, rgbmask RGBmask, order ByteOrder) (color.RGBA, bool) {
+	var s [4]uint8
+	if len(b) < 2 || len(b) > len(s) {
+		return color.RGBA{}, false
+	}
+	copy(s[:], b)
+	if order.swapped() {
+		swapPixelBytes(s[:len(b)], len(b))
+	}
+	switch len(b) {
+	case 4:
+		q := PIXEL32{s[0], s[1], s[2], s[3]}
+		return q.GetColor(rgbmask), true
+	case 3:
+		q := PIXEL24{s[0], s[1], s[2]}
+		return q.GetColor(rgbmask), true
+	}
+	q := PIXEL16{s[0], s[1]}
+	return q.GetColor(rgbmask), true
+}
+
+// Encode c to the raw pixel b in the byte order in place
+func setBytesColor(b []byte, c color.RGBA, rgbmask RGBmask, order ByteOrder) {
+	switch len(b) {
+	case 4:
+		var q PIXEL32
+		q.SetColor(c, rgbmask)
+		copy(b, q[:])
+	case 3:
+		var q PIXEL24
+		q.SetColor(c, rgbmask)
+		copy(b, q[:])
+	case 2:
+		var q PIXEL16
+		q.SetColor(c, rgbmask)
+		copy(b, q[:])
+	default:
+		return
+	}
+	if order.swapped() {
+		swapPixelBytes(b, len(b))
+	}
+}
+
 type PIXELARRAY interface {
 	StoreImage(src image.Image, rgbmask RGBmask)
 	GetWidth() int
@@ -227,6 +407,15 @@ func (p *PIXEL32ARRAY) StoreImage(img image.Image, rgbmask RGBmask) {
 	}
 }
 
+// Get the pixel at (x, y) in the array, nil if it's out of the array.
+// It points the pixel in the array.
+func (p *PIXEL32ARRAY) GetPixel(x int, y int) PIXEL {
+	if x < 0 || y < 0 || x >= p.width || y >= p.height {
+		return nil
+	}
+	return &p.pix[x+y*p.width]
+}
+
 func (p *PIXEL32ARRAY) PutPixelPat(dest_x int, dest_y int, pix PIXELARRAY) {
 	switch pix.(type) {
 	case *PIXEL32ARRAY:
@@ -271,6 +460,15 @@ func (p *PIXEL24ARRAY) StoreImage(img image.Image, rgbmask RGBmask) {
 	}
 }
 
+// Get the pixel at (x, y) in the array, nil if it's out of the array.
+// It points the pixel in the array.
+func (p *PIXEL24ARRAY) GetPixel(x int, y int) PIXEL {
+	if x < 0 || y < 0 || x >= p.width || y >= p.height {
+		return nil
+	}
+	return &p.pix[x+y*p.width]
+}
+
 func (p *PIXEL24ARRAY) PutPixelPat(dest_x int, dest_y int, pix PIXELARRAY) {
 	switch pix.(type) {
 	case *PIXEL24ARRAY:
@@ -315,6 +513,15 @@ func (p *PIXEL16ARRAY) StoreImage(img image.Image, rgbmask RGBmask) {
 	}
 }
 
+// Get the pixel at (x, y) in the array, nil if it's out of the array.
+// It points the pixel in the array.
+func (p *PIXEL16ARRAY) GetPixel(x int, y int) PIXEL {
+	if x < 0 || y < 0 || x >= p.width || y >= p.height {
+		return nil
+	}
+	return &p.pix[x+y*p.width]
+}
+
 func (p *PIXEL16ARRAY) PutPixelPat(dest_x int, dest_y int, pix PIXELARRAY) {
 	switch pix.(type) {
 	case *PIXEL16ARRAY:
@@ -359,6 +566,15 @@ func (p *PIXEL8ARRAY) StoreImage(img image.Image, rgbmask RGBmask) {
 	}
 }
 
+// Get the pixel at (x, y) in the array, nil if it's out of the array.
+// It points the pixel in the array.
+func (p *PIXEL8ARRAY) GetPixel(x int, y int) PIXEL {
+	if x < 0 || y < 0 || x >= p.width || y >= p.height {
+		return nil
+	}
+	return &p.pix[x+y*p.width]
+}
+
 func (p *PIXEL8ARRAY) PutPixelPat(dest_x int, dest_y int, pix PIXELARRAY) {
 	switch pix.(type) {
 	case *PIXEL8ARRAY:
@@ -377,3 +593,28 @@ func (p *PIXEL8ARRAY) PutPixelPat(dest_x int, dest_y int, pix PIXELARRAY) {
 		}
 	}
 }
+
+// Get the pixel at (x, y) in the array, nil if it's out of the array
+// or the array type is unknown. It points the pixel in the array.
+func arrayPixel(p PIXELARRAY, x int, y int) PIXEL {
+	switch q := p.(type) {
+	case *PIXEL32ARRAY:
+		return q.GetPixel(x, y)
+	case *PIXEL24ARRAY:
+		return q.GetPixel(x, y)
+	case *PIXEL16ARRAY:
+		return q.GetPixel(x, y)
+	case *PIXEL8ARRAY:
+		return q.GetPixel(x, y)
+	}
+	return nil
+}
+
+// Store the image to the array in the byte order
+func storeImage(p PIXELARRAY, img image.Image, rgbmask RGBmask, order ByteOrder) {
+	p.StoreImage(img, rgbmask)
+	if order.swapped() {
+		b, bpp := pixelArrayBytes(p)
+		swapPixelBytes(b, bpp)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Save and load PIXELARRAY in the native pixel format
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// File format, the header values are big endian:
+//   "WSPA", version, flags, depth, reserved
+//   width, height (uint32)
+//   RGBmask (8 x uint32)
+//   raw pixels and the packed mask bits (MSB first), zlib compressed
+//   if pixelFileCompress is set.
+const (
+	pixelFileMagic   = "WSPA"
+	pixelFileVersion = 1
+	pixelFileMaxSize = 1 << 16 // maximum width and height
+	pixelFileMaxData = 1 << 28 // maximum bytes of the pixels
+)
+
+// pixelFile flags
+const (
+	pixelFileCompress  = 1 << iota // the data is zlib compressed
+	pixelFileBigEndian             // the pixels are big endian
+)
+
+type pixelFileHeader struct {
+	Magic    [4]byte
+	Version  uint8
+	Flags    uint8
+	Depth    uint8
+	Reserved uint8
+	Width    uint32
+	Height   uint32
+	RGBmask  RGBmask
+}
+
+// Write the PIXELARRAY with the RGBmask of the display. The pixels are
+// stored as the raw framebuffer data, so it's loaded without conversion.
+func (wsd *WsDisplay) SavePixelArray(w io.Writer, p PIXELARRAY, compress bool) error {
+	if err := wsd.checkPixelArray(p); err != nil {
+		return err
+	}
+	hdr := pixelFileHeader{
+		Version: pixelFileVersion,
+		Depth:   uint8(wsd.GetDepth()),
+		Width:   uint32(p.GetWidth()),
+		Height:  uint32(p.GetHeight()),
+		RGBmask: wsd.GetRGBmask(),
+	}
+	copy(hdr.Magic[:], pixelFileMagic)
+	if compress {
+		hdr.Flags |= pixelFileCompress
+	}
+	if wsd.GetByteOrder() == BigEndian {
+		hdr.Flags |= pixelFileBigEndian
+	}
+	if err := binary.Write(w, binary.BigEndian, &hdr); err != nil {
+		return err
+	}
+
+	var zw *zlib.Writer
+	if compress {
+		zw = zlib.NewWriter(w)
+		w = zw
+	}
+	pix, _ := pixelArrayBytes(p)
+	if _, err := w.Write(pix); err != nil {
+		return err
+	}
+	mask := p.GetMasks()
+	bits := make([]byte, (len(mask)+7)/8)
+	for i, m := range mask {
+		if m {
+			bits[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	if _, err := w.Write(bits); err != nil {
+		return err
+	}
+	if zw != nil {
+		return zw.Close()
+	}
+	return nil
+}
+
+// Read the PIXELARRAY written by SavePixelArray. It fails if the depth,
+// the RGBmask or the byte order is different from the display.
+func (wsd *WsDisplay) LoadPixelArray(r io.Reader) (PIXELARRAY, error) {
+	br := bufio.NewReader(r)
+	var hdr pixelFileHeader
+	if err := binary.Read(br, binary.BigEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr.Magic[:]) != pixelFileMagic {
+		return nil, errors.New("Not PixelArray file")
+	}
+	if hdr.Version != pixelFileVersion {
+		return nil, errors.New("Unsupported PixelArray file version")
+	}
+	if int(hdr.Depth) != wsd.GetDepth() {
+		return nil, errors.New("Unmatch PixelDepth")
+	}
+	if hdr.RGBmask != wsd.GetRGBmask() {
+		return nil, errors.New("Unmatch RGBmask")
+	}
+	if (hdr.Flags&pixelFileBigEndian != 0) != (wsd.GetByteOrder() == BigEndian) {
+		return nil, errors.New("Unmatch byte order")
+	}
+	if hdr.Width > pixelFileMaxSize || hdr.Height > pixelFileMaxSize {
+		return nil, errors.New("Too large PixelArray")
+	}
+	npix := uint64(hdr.Width) * uint64(hdr.Height)
+	pixBytes := npix * uint64(wsd.bytesPerPixel())
+	if pixBytes > pixelFileMaxData {
+		return nil, errors.New("Too large PixelArray")
+	}
+
+	var src io.Reader = br
+	if hdr.Flags&pixelFileCompress != 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		src = zr
+	}
+	// read the payload before allocating the array, the buffer grows
+	// only as the data is read, so a short file can't make a large
+	// allocation.
+	size := int64(pixBytes + (npix+7)/8)
+	var data bytes.Buffer
+	if n, err := io.CopyN(&data, src, size); n < size {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	p, err := wsd.newPixelArraySize(int(hdr.Width), int(hdr.Height))
+	if err != nil {
+		return nil, err
+	}
+	pix, _ := pixelArrayBytes(p)
+	copy(pix, data.Bytes())
+	bits := data.Bytes()[pixBytes:]
+	mask := p.GetMasks()
+	for i := range mask {
+		mask[i] = bits[i/8]&(0x80>>uint(i%8)) != 0
+	}
+	return p, nil
+}
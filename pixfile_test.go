@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for SavePixelArray and LoadPixelArray
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Save the 5x3 PixelArray with the mask of the checker pattern
+func testPixelFile(t *testing.T, wsd *WsDisplay, compress bool) (PIXELARRAY, []byte) {
+	wsd.FillBox(image.Rect(0, 0, 5, 3), wsd.NewPixel(color.RGBA{10, 20, 30, 255}))
+	wsd.SetPixel(2, 1, wsd.NewPixel(color.RGBA{255, 0, 0, 255}))
+	p, err := wsd.GetPixelArray(image.Rect(0, 0, 5, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range p.GetMasks() {
+		p.GetMasks()[i] = i%2 == 0
+	}
+	var buf bytes.Buffer
+	if err := wsd.SavePixelArray(&buf, p, compress); err != nil {
+		t.Fatal(err)
+	}
+	return p, buf.Bytes()
+}
+
+func TestPixelFileRoundTrip(t *testing.T) {
+	for _, depth := range []int{16, 24, 32} {
+		for _, compress := range []bool{false, true} {
+			wsd := newTestDisplay(t, 8, 8, depth)
+			p, data := testPixelFile(t, wsd, compress)
+			q, err := wsd.LoadPixelArray(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("depth %d compress %v: %v", depth, compress, err)
+			}
+			pb, _ := pixelArrayBytes(p)
+			qb, _ := pixelArrayBytes(q)
+			if q.GetWidth() != 5 || q.GetHeight() != 3 || !bytes.Equal(pb, qb) {
+				t.Errorf("depth %d compress %v: pixels differ", depth, compress)
+			}
+			for i, m := range q.GetMasks() {
+				if m != p.GetMasks()[i] {
+					t.Errorf("depth %d compress %v: mask %d differs", depth, compress, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestPixelFileMismatch(t *testing.T) {
+	_, data := testPixelFile(t, newTestDisplay(t, 8, 8, 32), false)
+	if _, err := newTestDisplay(t, 8, 8, 24).LoadPixelArray(bytes.NewReader(data)); err == nil {
+		t.Error("no error for the different depth")
+	}
+	wsd, err := NewMemDisplay(8, 8, 32, RGBmask{
+		Red_offset: 0, Red_size: 8,
+		Green_offset: 8, Green_size: 8,
+		Blue_offset: 16, Blue_size: 8,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wsd.LoadPixelArray(bytes.NewReader(data)); err == nil {
+		t.Error("no error for the different RGBmask")
+	}
+}
+
+func TestPixelFileBroken(t *testing.T) {
+	wsd := newTestDisplay(t, 8, 8, 32)
+	_, data := testPixelFile(t, wsd, false)
+
+	// the header asks 65536x65536 pixels
+	huge := append([]byte{}, data...)
+	binary.BigEndian.PutUint32(huge[8:], 1<<16)
+	binary.BigEndian.PutUint32(huge[12:], 1<<16)
+	if _, err := wsd.LoadPixelArray(bytes.NewReader(huge)); err == nil {
+		t.Error("no error for the too large size")
+	}
+
+	// the header asks more pixels than the payload
+	short := append([]byte{}, data...)
+	binary.BigEndian.PutUint32(short[8:], 4000)
+	binary.BigEndian.PutUint32(short[12:], 4000)
+	if _, err := wsd.LoadPixelArray(bytes.NewReader(short)); err == nil {
+		t.Error("no error for the short payload")
+	}
+
+	for n := len(data) - 1; n > 0; n -= 7 {
+		if _, err := wsd.LoadPixelArray(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("no error for the truncated file of %d bytes", n)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Scanline polygon rasterizer
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// point in sub-pixel coordinates, pixel (x, y) covers [x, x+1)x[y, y+1)
+type fpoint struct {
+	x float64
+	y float64
+}
+
+// center of the pixel (x, y)
+func pixelCenter(x int, y int) fpoint {
+	return fpoint{float64(x) + 0.5, float64(y) + 0.5}
+}
+
+type edge struct {
+	x0, y0 float64 // upper end
+	x1, y1 float64 // lower end
+	dir    int     // +1: downward, -1: upward
+}
+
+type crossing struct {
+	x   float64
+	dir int
+}
+
+// Rule to decide the inside of the polygon
+type FillRule int
+
+const (
+	NonZero FillRule = iota // non-zero winding rule
+	EvenOdd                 // even-odd rule
+)
+
+// Make edge list from polygons, horizontal edges are dropped.
+func makeEdges(polys [][]fpoint) []edge {
+	var edges []edge
+	for _, poly := range polys {
+		n := len(poly)
+		for i := 0; i < n; i++ {
+			a := poly[i]
+			b := poly[(i+1)%n]
+			if a.y == b.y {
+				continue
+			}
+			if a.y < b.y {
+				edges = append(edges, edge{a.x, a.y, b.x, b.y, 1})
+			} else {
+				edges = append(edges, edge{b.x, b.y, a.x, a.y, -1})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+	return edges
+}
+
+// Scanline converter, scan() must be called with increasing y.
+type scanner struct {
+	edges  []edge
+	active []edge
+	next   int
+	xs     []crossing
+	ymin   float64
+	ymax   float64
+	xmin   float64
+	xmax   float64
+}
+
+func newScanner(polys [][]fpoint) *scanner {
+	sc := &scanner{edges: makeEdges(polys)}
+	if len(sc.edges) == 0 {
+		return sc
+	}
+	sc.ymin = sc.edges[0].y0
+	sc.ymax = sc.edges[0].y1
+	sc.xmin = math.Min(sc.edges[0].x0, sc.edges[0].x1)
+	sc.xmax = math.Max(sc.edges[0].x0, sc.edges[0].x1)
+	for _, e := range sc.edges {
+		sc.ymax = math.Max(sc.ymax, e.y1)
+		sc.xmin = math.Min(sc.xmin, math.Min(e.x0, e.x1))
+		sc.xmax = math.Max(sc.xmax, math.Max(e.x0, e.x1))
+	}
+	return sc
+}
+
+// Find the inside spans [xa, xb) on the line sy with the rule
+func (sc *scanner) scan(sy float64, rule FillRule, span func(xa float64, xb float64)) {
+	for sc.next < len(sc.edges) && sc.edges[sc.next].y0 <= sy {
+		sc.active = append(sc.active, sc.edges[sc.next])
+		sc.next++
+	}
+	sc.xs = sc.xs[:0]
+	j := 0
+	for _, e := range sc.active {
+		if e.y1 <= sy {
+			continue
+		}
+		sc.active[j] = e
+		j++
+		if e.y0 > sy {
+			continue
+		}
+		x := e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+		sc.xs = append(sc.xs, crossing{x, e.dir})
+	}
+	sc.active = sc.active[:j]
+	xs := sc.xs
+	sort.Slice(xs, func(a, b int) bool { return xs[a].x < xs[b].x })
+
+	w := 0
+	for i := 0; i < len(xs)-1; i++ {
+		if rule == EvenOdd {
+			w ^= 1
+		} else {
+			w += xs[i].dir
+		}
+		if w != 0 && xs[i].x < xs[i+1].x {
+			span(xs[i].x, xs[i+1].x)
+		}
+	}
+}
+
+// Limit the range [lo, hi) to [min, max), the result is in int range
+func clampRange(lo float64, hi float64, min int, max int) (float64, float64) {
+	return math.Max(lo, float64(min)), math.Min(hi, float64(max))
+}
+
+// Rasterize polygons with the rule, and call span(x0, x1, y)
+// for each covered half-open span [x0, x1) on the line y in bounds.
+// A pixel is covered if its center is inside of the polygons.
+func rasterize(polys [][]fpoint, rule FillRule, bounds image.Rectangle, span func(x0 int, x1 int, y int)) {
+	sc := newScanner(polys)
+	if len(sc.edges) == 0 || bounds.Empty() {
+		return
+	}
+	ymin, ymax := clampRange(sc.ymin, sc.ymax, bounds.Min.Y, bounds.Max.Y)
+	for y := int(math.Floor(ymin)); float64(y)+0.5 < ymax; y++ {
+		sc.scan(float64(y)+0.5, rule, func(xa float64, xb float64) {
+			xa, xb = clampRange(xa, xb, bounds.Min.X, bounds.Max.X)
+			x0 := int(math.Ceil(xa - 0.5))
+			x1 := int(math.Ceil(xb - 0.5))
+			if x0 < x1 {
+				span(x0, x1, y)
+			}
+		})
+	}
+}
+
+// Number of sub-scanlines in a pixel for anti-aliasing
+const aaSubScan = 4
+
+// Rasterize polygons with the rule and anti-aliasing, and call
+// row(x0, y, cov) for each line in bounds, cov[i] is the coverage
+// (0.0-1.0) of the pixel (x0+i, y).
+func rasterizeAA(polys [][]fpoint, rule FillRule, bounds image.Rectangle, row func(x0 int, y int, cov []float64)) {
+	sc := newScanner(polys)
+	if len(sc.edges) == 0 || bounds.Empty() {
+		return
+	}
+	xmin, xmax := clampRange(sc.xmin, sc.xmax, bounds.Min.X, bounds.Max.X-1)
+	if xmin > xmax {
+		return
+	}
+	bx0 := int(math.Floor(xmin))
+	bx1 := int(math.Floor(xmax)) + 1
+	cov := make([]float64, bx1-bx0)
+	const h = 1.0 / aaSubScan
+
+	ymin, ymax := clampRange(sc.ymin, sc.ymax, bounds.Min.Y, bounds.Max.Y)
+	for y := int(math.Floor(ymin)); float64(y) < ymax; y++ {
+		for i := range cov {
+			cov[i] = 0
+		}
+		for s := 0; s < aaSubScan; s++ {
+			sy := float64(y) + (float64(s)+0.5)*h
+			sc.scan(sy, rule, func(xa float64, xb float64) {
+				xa, xb = clampRange(xa, xb, bx0, bx1)
+				if xa >= xb {
+					return
+				}
+				xa -= float64(bx0)
+				xb -= float64(bx0)
+				ia := int(math.Floor(xa))
+				ib := int(math.Floor(xb))
+				if ib >= len(cov) {
+					ib = len(cov) - 1
+				}
+				if ia == ib {
+					cov[ia] += (xb - xa) * h
+					return
+				}
+				cov[ia] += (float64(ia+1) - xa) * h
+				for i := ia + 1; i < ib; i++ {
+					cov[i] += h
+				}
+				cov[ib] += (xb - float64(ib)) * h
+			})
+		}
+		row(bx0, y, cov)
+	}
+}
+
+// Signed area of the polygon, positive if clockwise on the screen
+func polygonArea(poly []fpoint) float64 {
+	a := 0.0
+	n := len(poly)
+	for i := 0; i < n; i++ {
+		p := poly[i]
+		q := poly[(i+1)%n]
+		a += p.x*q.y - q.x*p.y
+	}
+	return a / 2
+}
+
+// Reverse the polygon if it's counter-clockwise, so that the non-zero
+// rule gives the union of the polygons.
+func orientPolygon(poly []fpoint) []fpoint {
+	if polygonArea(poly) < 0 {
+		for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+			poly[i], poly[j] = poly[j], poly[i]
+		}
+	}
+	return poly
+}
+
+// Number of segments to approximate the circle of radius r
+// within 1/4 pixel error.
+func circleSegments(r float64) int {
+	if r <= 0.25 {
+		return 4
+	}
+	n := int(math.Ceil(math.Pi / math.Acos(1-0.25/r)))
+	if n < 8 {
+		n = 8
+	}
+	return n
+}
+
+// Polygon approximation of the circle
+func circlePolygon(c fpoint, r float64) []fpoint {
+	n := circleSegments(r)
+	poly := make([]fpoint, n)
+	for i := range poly {
+		t := 2 * math.Pi * float64(i) / float64(n)
+		poly[i] = fpoint{c.x + r*math.Cos(t), c.y + r*math.Sin(t)}
+	}
+	return poly
+}
+
+// Fill polygons with the rule and the pixel
+func (wsd *WsDisplay) fillPolygons(polys [][]fpoint, rule FillRule, p PIXEL) {
+	rasterize(polys, rule, wsd.clipBounds(), func(x0 int, x1 int, y int) {
+		wsd.fillSpan(x0, x1, y, p)
+	})
+}
+
+// Fill polygons with the rule and the pixel, with anti-aliasing
+func (wsd *WsDisplay) fillPolygonsAA(polys [][]fpoint, rule FillRule, p PIXEL) {
+	c := wsd.pixelColor(p)
+	rasterizeAA(polys, rule, wsd.clipBounds(), func(x0 int, y int, cov []float64) {
+		run := -1 // start of fully covered pixels
+		for i, f := range cov {
+			if f >= 0.999 {
+				if run < 0 {
+					run = i
+				}
+				continue
+			}
+			if run >= 0 {
+				wsd.fillSpan(x0+run, x0+i, y, p)
+				run = -1
+			}
+			wsd.blendPixel(x0+i, y, c, coverage(f))
+		}
+		if run >= 0 {
+			wsd.fillSpan(x0+run, x0+len(cov), y, p)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Raster operations on the raw pixel data
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+)
+
+// Raster operation of the source pixel and the screen
+type Rop int
+
+const (
+	RopCopy   Rop = iota // dst = src
+	RopXor               // dst = dst ^ src
+	RopAnd               // dst = dst & src
+	RopOr                // dst = dst | src
+	RopInvert            // dst = ^dst, the source is not used
+)
+
+// Apply rop to the raw pixel bytes
+func ropBytes(dst []byte, src []byte, rop Rop) {
+	switch rop {
+	case RopCopy:
+		copy(dst, src)
+	case RopXor:
+		for i := range dst {
+			dst[i] ^= src[i]
+		}
+	case RopAnd:
+		for i := range dst {
+			dst[i] &= src[i]
+		}
+	case RopOr:
+		for i := range dst {
+			dst[i] |= src[i]
+		}
+	case RopInvert:
+		for i := range dst {
+			dst[i] = ^dst[i]
+		}
+	}
+}
+
+// Raw bytes of the source pixel, p can be nil for RopInvert
+func (wsd *WsDisplay) ropSource(p PIXEL, rop Rop) []byte {
+	bpp := wsd.bytesPerPixel()
+	if rop == RopInvert && p == nil {
+		return make([]byte, bpp)
+	}
+	b := pixelBytes(p)
+	if len(b) != bpp {
+		return nil
+	}
+	return b
+}
+
+// Set the pixel with rop
+func (wsd *WsDisplay) SetPixelRop(px int, py int, p PIXEL, rop Rop) {
+	if !wsd.inClip(px, py) {
+		return
+	}
+	b := wsd.ropSource(p, rop)
+	if b == nil {
+		return
+	}
+	off := wsd.pixelOffset(px, py)
+	ropBytes(wsd.addr[off:off+len(b)], b, rop)
+}
+
+// Fill the area [rect.Min, rect.Max) with rop
+func (wsd *WsDisplay) FillBoxRop(rect image.Rectangle, p PIXEL, rop Rop) {
+	if rop == RopCopy {
+		wsd.FillBox(rect, p)
+		return
+	}
+	b := wsd.ropSource(p, rop)
+	if b == nil {
+		return
+	}
+	bpp := len(b)
+	sx, _ := wsd.pixelSteps()
+	for _, c := range wsd.clipRects() {
+		r := rect.Canon().Intersect(c)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			off := wsd.pixelOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				ropBytes(wsd.addr[off:off+bpp], b, rop)
+				off += sx
+			}
+		}
+	}
+}
+
+// Draw the outline of [rect.Min, rect.Max) with rop. Each pixel is
+// drawn once, so drawing twice by RopXor restores the screen.
+func (wsd *WsDisplay) DrawBoxRop(rect image.Rectangle, p PIXEL, rop Rop) {
+	boxOutline(rect, func(x int, y int) {
+		wsd.SetPixelRop(x, y, p, rop)
+	})
+}
+
+// Draw the line with rop
+func (wsd *WsDisplay) DrawLineRop(p0 image.Point, p1 image.Point, p PIXEL, rop Rop) {
+	linePoints(p0, p1, func(x int, y int) {
+		wsd.SetPixelRop(x, y, p, rop)
+	})
+}
+
+// Draw the PIXELARRAY with rop
+func (wsd *WsDisplay) PutPixelArrayRop(px int, py int, p PIXELARRAY, rop Rop) error {
+	if rop == RopCopy {
+		return wsd.PutPixelArray(px, py, p)
+	}
+	if err := wsd.checkPixelArray(p); err != nil {
+		return err
+	}
+	w := p.GetWidth()
+	dst := image.Rect(px, py, px+w, py+p.GetHeight())
+	src, bpp := pixelArrayBytes(p)
+	sx, _ := wsd.pixelSteps()
+	for _, c := range wsd.clipRects() {
+		r := dst.Intersect(c)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			off := wsd.pixelOffset(r.Min.X, y)
+			i := (r.Min.X - px + (y-py)*w) * bpp
+			for x := r.Min.X; x < r.Max.X; x++ {
+				ropBytes(wsd.addr[off:off+bpp], src[i:i+bpp], rop)
+				off += sx
+				i += bpp
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Virtual screen rotation, mapping logical coordinates to the framebuffer
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"errors"
+	"image"
+	"math"
+	"unsafe"
+)
+
+// Rotation of the logical screen on the framebuffer
+type Rotation int
+
+const (
+	Rotate0   Rotation = iota // same as the framebuffer
+	Rotate90                  // logical screen is drawn rotated 90 degrees clockwise
+	Rotate180                 // upside down
+	Rotate270                 // logical screen is drawn rotated 90 degrees counter-clockwise
+)
+
+// Set the rotation of the logical screen, for portrait-mounted panels.
+// GetWidth and GetHeight report the logical size, and all operations
+// use the logical coordinates. GetStride, GetPixelStride and GetBuffer*
+// are still for the raw framebuffer.
+// The clip region is in the logical coordinates and it is not changed.
+func (wsd *WsDisplay) SetRotation(r Rotation) error {
+	if r < Rotate0 || r > Rotate270 {
+		return errors.New("Invalid rotation")
+	}
+	wsd.rotation = r
+	return nil
+}
+
+func (wsd *WsDisplay) GetRotation() Rotation {
+	return wsd.rotation
+}
+
+// Map the logical (x, y) to the framebuffer
+func (wsd *WsDisplay) physical(x int, y int) (int, int) {
+	w := int(wsd.info.width)
+	h := int(wsd.info.height)
+	switch wsd.rotation {
+	case Rotate90:
+		return w - 1 - y, x
+	case Rotate180:
+		return w - 1 - x, h - 1 - y
+	case Rotate270:
+		return y, h - 1 - x
+	}
+	return x, y
+}
+
+// Map the logical rectangle to the framebuffer
+func (wsd *WsDisplay) physicalRect(r image.Rectangle) image.Rectangle {
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	x0, y0 := wsd.physical(r.Min.X, r.Min.Y)
+	x1, y1 := wsd.physical(r.Max.X-1, r.Max.Y-1)
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	return image.Rect(x0, y0, x1+1, y1+1)
+}
+
+// Bytes per pixel of the framebuffer
+func (wsd *WsDisplay) bytesPerPixel() int {
+	return int(wsd.info.bitsperpixel+7) / 8
+}
+
+// Byte offset of the logical (x, y) in the framebuffer memory
+func (wsd *WsDisplay) pixelOffset(x int, y int) int {
+	px, py := wsd.physical(x, y)
+	return int(wsd.info.offset) + py*int(wsd.info.stride) + px*wsd.bytesPerPixel()
+}
+
+// Byte steps in the framebuffer memory for the logical x+1 and y+1
+func (wsd *WsDisplay) pixelSteps() (int, int) {
+	bpp := wsd.bytesPerPixel()
+	stride := int(wsd.info.stride)
+	switch wsd.rotation {
+	case Rotate90:
+		return stride, -bpp
+	case Rotate180:
+		return -bpp, -stride
+	case Rotate270:
+		return -stride, bpp
+	}
+	return bpp, stride
+}
+
+// Raw bytes of the pixel
+func pixelBytes(p PIXEL) []byte {
+	switch q := p.(type) {
+	case *PIXEL32:
+		return q[:]
+	case *PIXEL24:
+		return q[:]
+	case *PIXEL16:
+		return q[:]
+	case *PIXEL8:
+		return q[:]
+	}
+	return nil
+}
+
+// New pixel of the depth from the raw bytes
+func newPixelFromBytes(b []byte) PIXEL {
+	switch len(b) {
+	case 4:
+		q := new(PIXEL32)
+		copy(q[:], b)
+		return q
+	case 3:
+		q := new(PIXEL24)
+		copy(q[:], b)
+		return q
+	case 2:
+		q := new(PIXEL16)
+		copy(q[:], b)
+		return q
+	case 1:
+		q := new(PIXEL8)
+		copy(q[:], b)
+		return q
+	}
+	return nil
+}
+
+// Raw bytes of the pixels in the PIXELARRAY and bytes per pixel
+func pixelArrayBytes(p PIXELARRAY) ([]byte, int) {
+	var ptr unsafe.Pointer
+	n := 0
+	bpp := 0
+	switch q := p.(type) {
+	case *PIXEL32ARRAY:
+		bpp = 4
+		n = len(q.pix)
+		if n > 0 {
+			ptr = unsafe.Pointer(&q.pix[0])
+		}
+	case *PIXEL24ARRAY:
+		bpp = 3
+		n = len(q.pix)
+		if n > 0 {
+			ptr = unsafe.Pointer(&q.pix[0])
+		}
+	case *PIXEL16ARRAY:
+		bpp = 2
+		n = len(q.pix)
+		if n > 0 {
+			ptr = unsafe.Pointer(&q.pix[0])
+		}
+	case *PIXEL8ARRAY:
+		bpp = 1
+		n = len(q.pix)
+		if n > 0 {
+			ptr = unsafe.Pointer(&q.pix[0])
+		}
+	}
+	if n == 0 {
+		return nil, bpp
+	}
+	return (*(*[math.MaxInt32]byte)(ptr))[: n*bpp : n*bpp], bpp
+}
+
+// Fill n pixels from the byte offset off with the step, by the raw pixel b
+func fillBytes(buf []byte, off int, step int, n int, b []byte) {
+	bpp := len(b)
+	if step == -bpp {
+		// reversed but contiguous
+		off -= (n - 1) * bpp
+		step = bpp
+	}
+	if step == bpp {
+		d := buf[off : off+n*bpp]
+		copy(d, b)
+		for i := bpp; i < len(d); i *= 2 {
+			copy(d[i:], d[:i])
+		}
+		return
+	}
+	for i := 0; i < n; i++ {
+		copy(buf[off:off+bpp], b)
+		off += step
+	}
+}
+
+// Copy the row of n raw pixels src to the byte offset off with the step
+func copyBytes(buf []byte, off int, step int, src []byte, bpp int) {
+	if step == bpp {
+		copy(buf[off:off+len(src)], src)
+		return
+	}
+	for i := 0; i < len(src); i += bpp {
+		copy(buf[off:off+bpp], src[i:i+bpp])
+		off += step
+	}
+}
+
+// Capture the area of the screen to new PIXELARRAY.
+// The area out of the screen is not captured and the mask is false.
+func (wsd *WsDisplay) GetPixelArray(rect image.Rectangle) (PIXELARRAY, error) {
+	rect = rect.Canon()
+	w := rect.Dx()
+	h := rect.Dy()
+	p, err := wsd.newPixelArraySize(w, h)
+	if err != nil {
+		return nil, err
+	}
+	mask := p.GetMasks()
+	dst, bpp := pixelArrayBytes(p)
+	r := rect.Intersect(wsd.screenRect())
+	sx, _ := wsd.pixelSteps()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		off := wsd.pixelOffset(r.Min.X, y)
+		i := (r.Min.X - rect.Min.X) + (y-rect.Min.Y)*w
+		for x := r.Min.X; x < r.Max.X; x++ {
+			copy(dst[i*bpp:(i+1)*bpp], wsd.addr[off:off+bpp])
+			mask[i] = true
+			off += sx
+			i++
+		}
+	}
+	return p, nil
+}
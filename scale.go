@@ -0,0 +1,267 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Image scaling and placement
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"math"
+)
+
+// Placement of the image in the area
+type FitMode int
+
+const (
+	FitCenter  FitMode = iota // no scaling, center of the area
+	FitContain                // scale to fit in the area, keep aspect ratio
+	FitCover                  // scale to cover the area, keep aspect ratio, cropped
+	FitStretch                // scale to the area, ignore aspect ratio
+	FitShrink                 // same as FitContain if larger than the area, otherwise FitCenter
+)
+
+// Get the destination rectangle of the w x h image in the area.
+// The result can be larger than the area by FitCover and FitCenter.
+func FitRect(w int, h int, area image.Rectangle, mode FitMode) image.Rectangle {
+	area = area.Canon()
+	aw := area.Dx()
+	ah := area.Dy()
+	if w <= 0 || h <= 0 || aw <= 0 || ah <= 0 {
+		return image.Rectangle{}
+	}
+	dw, dh := w, h
+	switch mode {
+	case FitStretch:
+		return area
+	case FitContain, FitCover, FitShrink:
+		if mode == FitShrink && w <= aw && h <= ah {
+			break
+		}
+		// compare aw/w and ah/h without rounding
+		wide := aw*h < ah*w
+		if mode == FitCover {
+			wide = !wide
+		}
+		if wide {
+			dw = aw
+			dh = int(math.Floor(float64(h)*float64(aw)/float64(w) + 0.5))
+		} else {
+			dh = ah
+			dw = int(math.Floor(float64(w)*float64(ah)/float64(h) + 0.5))
+		}
+		if dw < 1 {
+			dw = 1
+		}
+		if dh < 1 {
+			dh = 1
+		}
+	}
+	x := area.Min.X + (aw-dw)/2
+	y := area.Min.Y + (ah-dh)/2
+	return image.Rect(x, y, x+dw, y+dh)
+}
+
+// Resize the image to w x h with the interpolation
+func ResizeImage(img image.Image, w int, h int, interp Interpolation) *image.RGBA {
+	src := imageToRGBA(img)
+	sw := src.Rect.Dx()
+	sh := src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 || sw <= 0 || sh <= 0 {
+		return dst
+	}
+
+	if interp == Nearest {
+		for y := 0; y < h; y++ {
+			sy := (2*y + 1) * sh / (2 * h)
+			for x := 0; x < w; x++ {
+				sx := (2*x + 1) * sw / (2 * w)
+				copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4],
+					src.Pix[sy*src.Stride+sx*4:sy*src.Stride+sx*4+4])
+			}
+		}
+		return dst
+	}
+
+	// horizontal pass to float buffer
+	xw := resampleWeights(sw, w, interp)
+	tmp := make([]float64, w*sh*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, ws := range xw {
+			var s [4]float64
+			for _, k := range ws {
+				for c := 0; c < 4; c++ {
+					s[c] += float64(row[k.i*4+c]) * k.w
+				}
+			}
+			copy(tmp[(y*w+x)*4:], s[:])
+		}
+	}
+	// vertical pass
+	yw := resampleWeights(sh, h, interp)
+	for y, ws := range yw {
+		for x := 0; x < w; x++ {
+			var s [4]float64
+			for _, k := range ws {
+				for c := 0; c < 4; c++ {
+					s[c] += tmp[(k.i*w+x)*4+c] * k.w
+				}
+			}
+			a := clamp255(s[3])
+			i := y*dst.Stride + x*4
+			for c := 0; c < 3; c++ {
+				v := clamp255(s[c])
+				if v > a {
+					v = a
+				}
+				dst.Pix[i+c] = uint8(v)
+			}
+			dst.Pix[i+3] = uint8(a)
+		}
+	}
+	return dst
+}
+
+// Create new PIXELARRAY of the image resized to w x h
+func (wsd *WsDisplay) NewScaledPixelArray(img image.Image, w int, h int, interp Interpolation) (PIXELARRAY, error) {
+	p, err := wsd.NewPixelArray()
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Dx() == w && b.Dy() == h {
+		wsd.storeImage(p, img)
+	} else {
+		wsd.storeImage(p, ResizeImage(img, w, h, interp))
+	}
+	return p, nil
+}
+
+// Draw the image in the area with the placement mode
+func (wsd *WsDisplay) PutImageFit(img image.Image, area image.Rectangle, mode FitMode, interp Interpolation) error {
+	b := img.Bounds()
+	r := FitRect(b.Dx(), b.Dy(), area, mode)
+	if r.Empty() {
+		return nil
+	}
+	p, err := wsd.NewScaledPixelArray(img, r.Dx(), r.Dy(), interp)
+	if err != nil {
+		return err
+	}
+	wsd.PushClip(area)
+	defer wsd.PopClip()
+	return wsd.PutPixelArray(r.Min.X, r.Min.Y, p)
+}
+
+// Draw the image in the whole screen with the placement mode
+func (wsd *WsDisplay) PutImageScreen(img image.Image, mode FitMode, interp Interpolation) error {
+	return wsd.PutImageFit(img, wsd.screenRect(), mode, interp)
+}
+
+type resampleWeight struct {
+	i int     // source index
+	w float64 // weight
+}
+
+// Filter kernel and its radius
+func resampleKernel(interp Interpolation) (func(float64) float64, float64) {
+	switch interp {
+	case Bicubic:
+		return cubicWeight, 2
+	case Lanczos:
+		return lanczosWeight, 3
+	}
+	return func(t float64) float64 {
+		t = math.Abs(t)
+		if t < 1 {
+			return 1 - t
+		}
+		return 0
+	}, 1
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// Lanczos3 weight
+func lanczosWeight(t float64) float64 {
+	if t <= -3 || t >= 3 {
+		return 0
+	}
+	return sinc(t) * sinc(t/3)
+}
+
+// Weights of the source pixels for each destination pixel
+func resampleWeights(sn int, dn int, interp Interpolation) [][]resampleWeight {
+	kernel, radius := resampleKernel(interp)
+	scale := float64(sn) / float64(dn)
+	fscale := math.Max(scale, 1) // widen the filter for down scaling
+	support := radius * fscale
+
+	res := make([][]resampleWeight, dn)
+	for d := 0; d < dn; d++ {
+		center := (float64(d)+0.5)*scale - 0.5
+		i0 := int(math.Ceil(center - support))
+		i1 := int(math.Floor(center + support))
+		var ws []resampleWeight
+		total := 0.0
+		for i := i0; i <= i1; i++ {
+			w := kernel((float64(i) - center) / fscale)
+			if w == 0 {
+				continue
+			}
+			si := i
+			if si < 0 {
+				si = 0
+			} else if si >= sn {
+				si = sn - 1
+			}
+			ws = append(ws, resampleWeight{si, w})
+			total += w
+		}
+		if total != 0 {
+			for j := range ws {
+				ws[j].w /= total
+			}
+		} else {
+			c := int(center + 0.5)
+			if c < 0 {
+				c = 0
+			} else if c >= sn {
+				c = sn - 1
+			}
+			ws = []resampleWeight{{c, 1}}
+		}
+		res[d] = ws
+	}
+	return res
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Ellipses, arcs, pie slices and rounded rectangles
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"math"
+)
+
+// Points of the ellipse in the first quadrant (midpoint algorithm),
+// x and y are the offsets from the center.
+func ellipseQuadrant(rx int, ry int, plot func(x int, y int)) {
+	if ry == 0 {
+		for x := 0; x <= rx; x++ {
+			plot(x, 0)
+		}
+		return
+	}
+	rx2 := float64(rx) * float64(rx)
+	ry2 := float64(ry) * float64(ry)
+	x := 0
+	y := ry
+	dx := 0.0
+	dy := 2 * rx2 * float64(y)
+
+	// region 1, slope > -1
+	d1 := ry2 - rx2*float64(ry) + rx2/4
+	for dx < dy {
+		plot(x, y)
+		x++
+		dx += 2 * ry2
+		if d1 < 0 {
+			d1 += dx + ry2
+		} else {
+			y--
+			dy -= 2 * rx2
+			d1 += dx - dy + ry2
+		}
+	}
+	// region 2, slope <= -1
+	fx := float64(x) + 0.5
+	fy := float64(y) - 1
+	d2 := ry2*fx*fx + rx2*fy*fy - rx2*ry2
+	for y >= 0 {
+		plot(x, y)
+		y--
+		dy -= 2 * rx2
+		if d2 > 0 {
+			d2 += rx2 - dy
+		} else {
+			x++
+			dx += 2 * ry2
+			d2 += dx - dy + rx2
+		}
+	}
+}
+
+// Half width of the ellipse for each line, ext[y] is the maximum x
+// offset at the y offset from the center.
+func ellipseExtents(rx int, ry int) []int {
+	ext := make([]int, ry+1)
+	ellipseQuadrant(rx, ry, func(x int, y int) {
+		if x > ext[y] {
+			ext[y] = x
+		}
+	})
+	return ext
+}
+
+// Draw ellipse, center (px, py) and radius (rx, ry)
+func (wsd *WsDisplay) DrawEllipse(px int, py int, rx int, ry int, p PIXEL) {
+	if rx < 0 || ry < 0 {
+		return
+	}
+	ellipseQuadrant(rx, ry, func(x int, y int) {
+		wsd.plot4(px, py, x, y, p)
+	})
+}
+
+// Fill ellipse, center (px, py) and radius (rx, ry)
+func (wsd *WsDisplay) FillEllipse(px int, py int, rx int, ry int, p PIXEL) {
+	ellipseSpans(px, py, rx, ry, func(x0 int, x1 int, y int) {
+		wsd.fillSpan(x0, x1, y, p)
+	})
+}
+
+// Spans of the filled ellipse
+func ellipseSpans(px int, py int, rx int, ry int, span func(x0 int, x1 int, y int)) {
+	if rx < 0 || ry < 0 {
+		return
+	}
+	for y, x := range ellipseExtents(rx, ry) {
+		span(px-x, px+x+1, py+y)
+		if y != 0 {
+			span(px-x, px+x+1, py-y)
+		}
+	}
+}
+
+// Set 4 pixels by symmetry, without overlapping on the axes
+func (wsd *WsDisplay) plot4(px int, py int, x int, y int, p PIXEL) {
+	wsd.SetPixel(px+x, py+y, p)
+	if x != 0 {
+		wsd.SetPixel(px-x, py+y, p)
+	}
+	if y != 0 {
+		wsd.SetPixel(px+x, py-y, p)
+		if x != 0 {
+			wsd.SetPixel(px-x, py-y, p)
+		}
+	}
+}
+
+// Angle range for arcs and pies, in degrees
+type angleRange struct {
+	start float64
+	sweep float64
+	rx    float64
+	ry    float64
+}
+
+func newAngleRange(rx int, ry int, start float64, sweep float64) angleRange {
+	if sweep < 0 {
+		start += sweep
+		sweep = -sweep
+	}
+	start = math.Mod(start, 360)
+	if start < 0 {
+		start += 360
+	}
+	if rx == 0 || ry == 0 {
+		rx, ry = 1, 1
+	}
+	return angleRange{start, sweep, float64(rx), float64(ry)}
+}
+
+// Check the offset (x, y) from the center is in the range.
+// The angle is the parameter of the ellipse same as Path.Arc().
+func (a angleRange) contains(x int, y int) bool {
+	if a.sweep >= 360 || (x == 0 && y == 0) {
+		return true
+	}
+	t := math.Atan2(float64(y)/a.ry, float64(x)/a.rx) * 180 / math.Pi
+	d := math.Mod(t-a.start, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d <= a.sweep
+}
+
+// Draw elliptical arc, center (px, py) and radius (rx, ry), from the angle
+// start to start+sweep in degrees. The angle is clockwise on the screen
+// from the positive x axis.
+func (wsd *WsDisplay) DrawArc(px int, py int, rx int, ry int, start float64, sweep float64, p PIXEL) {
+	if rx < 0 || ry < 0 {
+		return
+	}
+	a := newAngleRange(rx, ry, start, sweep)
+	plot := func(x int, y int) {
+		if a.contains(x, y) {
+			wsd.SetPixel(px+x, py+y, p)
+		}
+	}
+	ellipseQuadrant(rx, ry, func(x int, y int) {
+		plot(x, y)
+		if x != 0 {
+			plot(-x, y)
+		}
+		if y != 0 {
+			plot(x, -y)
+			if x != 0 {
+				plot(-x, -y)
+			}
+		}
+	})
+}
+
+// Fill pie slice of the ellipse, the angles are same as DrawArc().
+func (wsd *WsDisplay) FillPie(px int, py int, rx int, ry int, start float64, sweep float64, p PIXEL) {
+	pieSpans(px, py, rx, ry, start, sweep, func(x0 int, x1 int, y int) {
+		wsd.fillSpan(x0, x1, y, p)
+	})
+}
+
+// Spans of the filled pie slice
+func pieSpans(px int, py int, rx int, ry int, start float64, sweep float64, span func(x0 int, x1 int, y int)) {
+	if rx < 0 || ry < 0 {
+		return
+	}
+	a := newAngleRange(rx, ry, start, sweep)
+	if a.sweep >= 360 {
+		ellipseSpans(px, py, rx, ry, span)
+		return
+	}
+	line := func(y int, ext int) {
+		run := 0
+		for x := -ext; x <= ext; x++ {
+			if a.contains(x, y) {
+				run++
+				continue
+			}
+			if run > 0 {
+				span(px+x-run, px+x, py+y)
+				run = 0
+			}
+		}
+		if run > 0 {
+			span(px+ext+1-run, px+ext+1, py+y)
+		}
+	}
+	for y, x := range ellipseExtents(rx, ry) {
+		line(y, x)
+		if y != 0 {
+			line(-y, x)
+		}
+	}
+}
+
+// Clamp the corner radius of the rounded rectangle
+func cornerRadius(rect image.Rectangle, r int) int {
+	rect = rect.Canon()
+	m := rect.Dx()
+	if rect.Dy() < m {
+		m = rect.Dy()
+	}
+	if r > (m-1)/2 {
+		r = (m - 1) / 2
+	}
+	if r < 0 {
+		r = 0
+	}
+	return r
+}
+
+// Draw rounded rectangle, the area is [rect.Min, rect.Max) and
+// the corner radius is r.
+func (wsd *WsDisplay) DrawRoundRect(rect image.Rectangle, r int, p PIXEL) {
+	rect = rect.Canon()
+	if rect.Empty() {
+		return
+	}
+	r = cornerRadius(rect, r)
+	// centers of the corners
+	x0 := rect.Min.X + r
+	y0 := rect.Min.Y + r
+	x1 := rect.Max.X - 1 - r
+	y1 := rect.Max.Y - 1 - r
+
+	for x := x0 + 1; x < x1; x++ {
+		wsd.SetPixel(x, rect.Min.Y, p)
+		if rect.Max.Y-1 != rect.Min.Y {
+			wsd.SetPixel(x, rect.Max.Y-1, p)
+		}
+	}
+	for y := y0 + 1; y < y1; y++ {
+		wsd.SetPixel(rect.Min.X, y, p)
+		if rect.Max.X-1 != rect.Min.X {
+			wsd.SetPixel(rect.Max.X-1, y, p)
+		}
+	}
+	ellipseQuadrant(r, r, func(x int, y int) {
+		wsd.SetPixel(x0-x, y0-y, p)
+		if x0 != x1 || x != 0 {
+			wsd.SetPixel(x1+x, y0-y, p)
+		}
+		if y0 != y1 || y != 0 {
+			wsd.SetPixel(x0-x, y1+y, p)
+			if x0 != x1 || x != 0 {
+				wsd.SetPixel(x1+x, y1+y, p)
+			}
+		}
+	})
+}
+
+// Fill rounded rectangle, the area is [rect.Min, rect.Max) and
+// the corner radius is r.
+func (wsd *WsDisplay) FillRoundRect(rect image.Rectangle, r int, p PIXEL) {
+	roundRectSpans(rect, r, func(x0 int, x1 int, y int) {
+		wsd.fillSpan(x0, x1, y, p)
+	})
+}
+
+// Spans of the filled rounded rectangle
+func roundRectSpans(rect image.Rectangle, r int, span func(x0 int, x1 int, y int)) {
+	rect = rect.Canon()
+	if rect.Empty() {
+		return
+	}
+	r = cornerRadius(rect, r)
+	x0 := rect.Min.X + r
+	y0 := rect.Min.Y + r
+	x1 := rect.Max.X - 1 - r
+	y1 := rect.Max.Y - 1 - r
+
+	ext := ellipseExtents(r, r)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		dx := r
+		if y < y0 {
+			dx = ext[y0-y]
+		} else if y > y1 {
+			dx = ext[y-y1]
+		}
+		span(x0-dx, x1+dx+1, y)
+	}
+}
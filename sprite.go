@@ -0,0 +1,254 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Sprite sheet and texture atlas
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SpriteSheet is an image converted once and the frames in it.
+// The frames are drawn from the sheet without new PIXELARRAY.
+type SpriteSheet struct {
+	wsd    *WsDisplay
+	pix    PIXELARRAY
+	frames []image.Rectangle
+	names  map[string]int
+}
+
+// Create new sprite sheet of the image with no frames
+func (wsd *WsDisplay) NewSpriteSheet(img image.Image) (*SpriteSheet, error) {
+	p, err := wsd.NewPixelArray()
+	if err != nil {
+		return nil, err
+	}
+	wsd.storeImage(p, img)
+	return &SpriteSheet{wsd: wsd, pix: p, names: make(map[string]int)}, nil
+}
+
+// Create new sprite sheet of the image divided by fw x fh frames.
+// The frames are numbered from the top left in row order.
+func (wsd *WsDisplay) NewSpriteSheetGrid(img image.Image, fw int, fh int) (*SpriteSheet, error) {
+	s, err := wsd.NewSpriteSheet(img)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.AddGrid(image.Rect(0, 0, s.GetWidth(), s.GetHeight()), fw, fh, 0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SpriteSheet) GetWidth() int {
+	return s.pix.GetWidth()
+}
+
+func (s *SpriteSheet) GetHeight() int {
+	return s.pix.GetHeight()
+}
+
+// Get the converted sheet image
+func (s *SpriteSheet) GetPixelArray() PIXELARRAY {
+	return s.pix
+}
+
+// Add the frame in the sheet, name can be empty. It returns the index.
+func (s *SpriteSheet) AddFrame(name string, r image.Rectangle) (int, error) {
+	r = r.Canon()
+	if r.Empty() || !r.In(image.Rect(0, 0, s.GetWidth(), s.GetHeight())) {
+		return -1, fmt.Errorf("Frame %q %v is out of the sheet", name, r)
+	}
+	s.frames = append(s.frames, r)
+	i := len(s.frames) - 1
+	if name != "" {
+		s.names[name] = i
+	}
+	return i, nil
+}
+
+// Add the frames of fw x fh in the area with spacing between the frames
+func (s *SpriteSheet) AddGrid(area image.Rectangle, fw int, fh int, spacing int) error {
+	if fw <= 0 || fh <= 0 {
+		return errors.New("Invalid frame size")
+	}
+	for y := area.Min.Y; y+fh <= area.Max.Y; y += fh + spacing {
+		for x := area.Min.X; x+fw <= area.Max.X; x += fw + spacing {
+			if _, err := s.AddFrame("", image.Rect(x, y, x+fw, y+fh)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Number of the frames
+func (s *SpriteSheet) Len() int {
+	return len(s.frames)
+}
+
+// Get the rectangle of the frame i in the sheet
+func (s *SpriteSheet) Frame(i int) image.Rectangle {
+	return s.frames[i]
+}
+
+// Get the index of the named frame
+func (s *SpriteSheet) Lookup(name string) (int, bool) {
+	i, ok := s.names[name]
+	return i, ok
+}
+
+// Draw the frame i at (x, y), the masked pixels are not drawn
+func (s *SpriteSheet) Draw(x int, y int, i int) error {
+	if i < 0 || i >= len(s.frames) {
+		return errors.New("Frame index out of range")
+	}
+	s.wsd.putPixelArrayRectMasked(image.Pt(x, y), s.pix, s.frames[i])
+	return nil
+}
+
+// Draw the named frame at (x, y)
+func (s *SpriteSheet) DrawNamed(x int, y int, name string) error {
+	i, ok := s.names[name]
+	if !ok {
+		return fmt.Errorf("No frame %q", name)
+	}
+	return s.Draw(x, y, i)
+}
+
+// Frame in JSON atlas, same as TexturePacker's
+type atlasFrame struct {
+	Filename string `json:"filename"`
+	Frame    struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"frame"`
+}
+
+// Load the frames from JSON atlas. The "frames" is an array of
+// {"filename": name, "frame": {"x", "y", "w", "h"}} or an object of
+// name: {"frame": {...}}, the latter is added in name order.
+func (s *SpriteSheet) LoadAtlasJSON(r io.Reader) error {
+	var atlas struct {
+		Frames json.RawMessage `json:"frames"`
+	}
+	if err := json.NewDecoder(r).Decode(&atlas); err != nil {
+		return err
+	}
+	var frames []atlasFrame
+	if err := json.Unmarshal(atlas.Frames, &frames); err != nil {
+		hash := make(map[string]atlasFrame)
+		if err := json.Unmarshal(atlas.Frames, &hash); err != nil {
+			return errors.New("Invalid atlas frames")
+		}
+		names := make([]string, 0, len(hash))
+		for name := range hash {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f := hash[name]
+			f.Filename = name
+			frames = append(frames, f)
+		}
+	}
+	for _, f := range frames {
+		fr := image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H)
+		if _, err := s.AddFrame(f.Filename, fr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load the frames from INI atlas, a section for each frame:
+//   [name]
+//   x = 0
+//   y = 0
+//   w = 16
+//   h = 16
+func (s *SpriteSheet) LoadAtlasINI(r io.Reader) error {
+	var name string
+	var v [4]int // x, y, w, h
+	section := false
+	add := func() error {
+		if !section {
+			return nil
+		}
+		_, err := s.AddFrame(name, image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]))
+		return err
+	}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			if err := add(); err != nil {
+				return err
+			}
+			name = strings.TrimSpace(line[1 : len(line)-1])
+			v = [4]int{}
+			section = true
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || !section {
+			return fmt.Errorf("Atlas line %d: syntax error", n)
+		}
+		val, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("Atlas line %d: %v", n, err)
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "x":
+			v[0] = val
+		case "y":
+			v[1] = val
+		case "w":
+			v[2] = val
+		case "h":
+			v[3] = val
+		default:
+			return fmt.Errorf("Atlas line %d: unknown key", n)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return add()
+}
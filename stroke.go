@@ -0,0 +1,365 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Stroking operations; thick lines, dashes, caps and joins
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image"
+	"math"
+)
+
+// Shape of the end of the open lines
+type LineCap int
+
+const (
+	CapButt   LineCap = iota // end at the end point
+	CapRound                 // half circle
+	CapSquare                // extend a half of the width
+)
+
+// Shape of the corner of the lines
+type LineJoin int
+
+const (
+	JoinMiter LineJoin = iota // sharp corner, bevel if over the MiterLimit
+	JoinRound                 // round corner
+	JoinBevel                 // cut off corner
+)
+
+// Default limit of the ratio miter length / line width
+const DefaultMiterLimit = 4.0
+
+// Line style for Stroke* operations
+type LineStyle struct {
+	Width      float64   // line width in pixels, 1 if less than 1
+	Dash       []float64 // dash pattern, lengths of on and off. nil is solid
+	DashOffset float64   // start position in the dash pattern
+	Cap        LineCap
+	Join       LineJoin
+	MiterLimit float64 // 0 means DefaultMiterLimit
+}
+
+// Stroke the line from p0 to p1 with the style
+func (wsd *WsDisplay) StrokeLine(p0 image.Point, p1 image.Point, style LineStyle, p PIXEL) {
+	wsd.StrokePolyline([]image.Point{p0, p1}, style, p)
+}
+
+// Stroke the open polyline with the style
+func (wsd *WsDisplay) StrokePolyline(pts []image.Point, style LineStyle, p PIXEL) {
+	wsd.fillPolygons(strokePolygons(toFpoints(pts), false, style), NonZero, p)
+}
+
+// Stroke the closed polygon with the style
+func (wsd *WsDisplay) StrokePolygon(pts []image.Point, style LineStyle, p PIXEL) {
+	wsd.fillPolygons(strokePolygons(toFpoints(pts), true, style), NonZero, p)
+}
+
+// Stroke the box with the style, the line is centered on the edge of DrawBox
+func (wsd *WsDisplay) StrokeBox(rect image.Rectangle, style LineStyle, p PIXEL) {
+	rect = rect.Canon()
+	if rect.Empty() {
+		return
+	}
+	right := rect.Max.X - 1
+	bottom := rect.Max.Y - 1
+	wsd.StrokePolygon([]image.Point{
+		rect.Min,
+		image.Pt(right, rect.Min.Y),
+		image.Pt(right, bottom),
+		image.Pt(rect.Min.X, bottom),
+	}, style, p)
+}
+
+// Stroke the circle with the style, center (px, py) and radius r
+func (wsd *WsDisplay) StrokeCircle(px int, py int, r int, style LineStyle, p PIXEL) {
+	poly := circlePolygon(pixelCenter(px, py), float64(r))
+	wsd.fillPolygons(strokePolygons(poly, true, style), NonZero, p)
+}
+
+// Draw 1 pixel width polyline
+func (wsd *WsDisplay) DrawPolyline(pts []image.Point, p PIXEL) {
+	for i := 0; i+1 < len(pts); i++ {
+		wsd.DrawLine(pts[i], pts[i+1], p)
+	}
+	if len(pts) == 1 {
+		wsd.SetPixel(pts[0].X, pts[0].Y, p)
+	}
+}
+
+// Convert to the centers of the pixels
+func toFpoints(pts []image.Point) []fpoint {
+	q := make([]fpoint, len(pts))
+	for i, pt := range pts {
+		q[i] = pixelCenter(pt.X, pt.Y)
+	}
+	return q
+}
+
+func distance(a fpoint, b fpoint) float64 {
+	return math.Hypot(b.x-a.x, b.y-a.y)
+}
+
+// unit vector from a to b
+func direction(a fpoint, b fpoint) fpoint {
+	d := distance(a, b)
+	return fpoint{(b.x - a.x) / d, (b.y - a.y) / d}
+}
+
+// Make polygons which covers the stroke of the polyline.
+// Filling them with the non-zero rule draws the stroke.
+func strokePolygons(pts []fpoint, closed bool, style LineStyle) [][]fpoint {
+	// remove duplicated points
+	var q []fpoint
+	for _, pt := range pts {
+		if len(q) > 0 && q[len(q)-1] == pt {
+			continue
+		}
+		q = append(q, pt)
+	}
+	if closed && len(q) > 1 && q[0] == q[len(q)-1] {
+		q = q[:len(q)-1]
+	}
+	if len(q) == 0 {
+		return nil
+	}
+	if closed && len(q) < 3 {
+		closed = false
+	}
+
+	if dashed(style.Dash) {
+		if closed {
+			q = append(q, q[0])
+		}
+		var polys [][]fpoint
+		for _, d := range dashPolyline(q, style.Dash, style.DashOffset) {
+			polys = append(polys, strokeOpen(d, style)...)
+		}
+		return polys
+	}
+	if closed {
+		return strokeClosed(q, style)
+	}
+	return strokeOpen(q, style)
+}
+
+func halfWidth(style LineStyle) float64 {
+	if style.Width < 1 {
+		return 0.5
+	}
+	return style.Width / 2
+}
+
+// Quad polygon of the segment a-b
+func segmentPolygon(a fpoint, b fpoint, hw float64) []fpoint {
+	u := direction(a, b)
+	n := fpoint{-u.y * hw, u.x * hw}
+	return orientPolygon([]fpoint{
+		{a.x + n.x, a.y + n.y},
+		{b.x + n.x, b.y + n.y},
+		{b.x - n.x, b.y - n.y},
+		{a.x - n.x, a.y - n.y},
+	})
+}
+
+// Stroke of open polyline which has no duplicated points
+func strokeOpen(pts []fpoint, style LineStyle) [][]fpoint {
+	hw := halfWidth(style)
+	var polys [][]fpoint
+
+	if len(pts) == 1 {
+		c := pts[0]
+		switch style.Cap {
+		case CapRound:
+			polys = append(polys, circlePolygon(c, hw))
+		case CapSquare:
+			polys = append(polys, []fpoint{
+				{c.x - hw, c.y - hw}, {c.x + hw, c.y - hw},
+				{c.x + hw, c.y + hw}, {c.x - hw, c.y + hw},
+			})
+		}
+		return polys
+	}
+
+	last := len(pts) - 1
+	for i := 0; i < last; i++ {
+		a := pts[i]
+		b := pts[i+1]
+		if style.Cap == CapSquare {
+			u := direction(a, b)
+			if i == 0 {
+				a = fpoint{a.x - u.x*hw, a.y - u.y*hw}
+			}
+			if i == last-1 {
+				b = fpoint{b.x + u.x*hw, b.y + u.y*hw}
+			}
+		}
+		polys = append(polys, segmentPolygon(a, b, hw))
+	}
+	for i := 1; i < last; i++ {
+		polys = append(polys, joinPolygon(pts[i-1], pts[i], pts[i+1], hw, style)...)
+	}
+	if style.Cap == CapRound {
+		polys = append(polys, circlePolygon(pts[0], hw), circlePolygon(pts[last], hw))
+	}
+	return polys
+}
+
+// Stroke of closed polygon which has no duplicated points
+func strokeClosed(pts []fpoint, style LineStyle) [][]fpoint {
+	hw := halfWidth(style)
+	var polys [][]fpoint
+	n := len(pts)
+	for i := 0; i < n; i++ {
+		polys = append(polys, segmentPolygon(pts[i], pts[(i+1)%n], hw))
+	}
+	for i := 0; i < n; i++ {
+		polys = append(polys,
+			joinPolygon(pts[(i+n-1)%n], pts[i], pts[(i+1)%n], hw, style)...)
+	}
+	return polys
+}
+
+// Polygon of the join at v, between segment a-v and v-b
+func joinPolygon(a fpoint, v fpoint, b fpoint, hw float64, style LineStyle) [][]fpoint {
+	if style.Join == JoinRound {
+		return [][]fpoint{circlePolygon(v, hw)}
+	}
+	u1 := direction(a, v)
+	u2 := direction(v, b)
+	cross := u1.x*u2.y - u1.y*u2.x
+	if math.Abs(cross) < 1e-9 && u1.x*u2.x+u1.y*u2.y > 0 {
+		// straight, no join is needed
+		return nil
+	}
+	// outer side of the corner
+	s := hw
+	if cross > 0 {
+		s = -hw
+	}
+	n1 := fpoint{-u1.y, u1.x}
+	n2 := fpoint{-u2.y, u2.x}
+	p1 := fpoint{v.x + n1.x*s, v.y + n1.y*s}
+	p2 := fpoint{v.x + n2.x*s, v.y + n2.y*s}
+
+	if style.Join == JoinMiter {
+		limit := style.MiterLimit
+		if limit <= 0 {
+			limit = DefaultMiterLimit
+		}
+		m := fpoint{n1.x + n2.x, n1.y + n2.y}
+		cosHalf := math.Hypot(m.x, m.y) / 2 // cos(turning angle / 2)
+		if cosHalf > 0 && 1/cosHalf <= limit {
+			k := s / (2 * cosHalf * cosHalf)
+			mp := fpoint{v.x + m.x*k, v.y + m.y*k}
+			return [][]fpoint{orientPolygon([]fpoint{v, p1, mp, p2})}
+		}
+	}
+	return [][]fpoint{orientPolygon([]fpoint{v, p1, p2})}
+}
+
+// Check the dash pattern is valid
+func dashed(dash []float64) bool {
+	total := 0.0
+	for _, d := range dash {
+		if d < 0 {
+			return false
+		}
+		total += d
+	}
+	return total > 0
+}
+
+// Split the polyline into the dashes
+func dashPolyline(pts []fpoint, dash []float64, offset float64) [][]fpoint {
+	pattern := dash
+	if len(pattern)%2 == 1 {
+		pattern = append(append([]float64{}, dash...), dash...)
+	}
+	total := 0.0
+	for _, d := range pattern {
+		total += d
+	}
+
+	// find the start position in the pattern
+	off := math.Mod(offset, total)
+	if off < 0 {
+		off += total
+	}
+	i := 0
+	for off >= pattern[i] {
+		off -= pattern[i]
+		i = (i + 1) % len(pattern)
+	}
+	left := pattern[i] - off
+	on := i%2 == 0
+
+	var dashes [][]fpoint
+	var cur []fpoint
+	if on {
+		cur = []fpoint{pts[0]}
+	}
+	for k := 0; k+1 < len(pts); k++ {
+		a := pts[k]
+		b := pts[k+1]
+		seg := distance(a, b)
+		pos := 0.0
+		for seg-pos > left {
+			pos += left
+			t := pos / seg
+			q := fpoint{a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t}
+			if on {
+				dashes = append(dashes, append(cur, q))
+				cur = nil
+			} else {
+				cur = []fpoint{q}
+			}
+			on = !on
+			i = (i + 1) % len(pattern)
+			left = pattern[i]
+		}
+		left -= seg - pos
+		if on {
+			cur = append(cur, b)
+		}
+	}
+	if on && len(cur) > 0 {
+		dashes = append(dashes, cur)
+	}
+
+	// remove duplicated points in each dash
+	for j, d := range dashes {
+		var q []fpoint
+		for _, pt := range d {
+			if len(q) > 0 && q[len(q)-1] == pt {
+				continue
+			}
+			q = append(q, pt)
+		}
+		dashes[j] = q
+	}
+	return dashes
+}
@@ -0,0 +1,442 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// 2D affine transforms, and transformed image drawing
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Affine is a 2D affine transform matrix, maps (x, y) to
+//   x' = m[0]*x + m[1]*y + m[2]
+//   y' = m[3]*x + m[4]*y + m[5]
+// The coordinates are same as Path, an integer point is the center of
+// the pixel.
+type Affine [6]float64
+
+// Identity transform
+func IdentityAffine() Affine {
+	return Affine{1, 0, 0, 0, 1, 0}
+}
+
+// Translate by (tx, ty)
+func TranslateAffine(tx float64, ty float64) Affine {
+	return Affine{1, 0, tx, 0, 1, ty}
+}
+
+// Rotate around the origin by deg degrees, clockwise on the screen
+func RotateAffine(deg float64) Affine {
+	s, c := math.Sincos(deg * math.Pi / 180)
+	return Affine{c, -s, 0, s, c, 0}
+}
+
+// Scale by (sx, sy) around the origin
+func ScaleAffine(sx float64, sy float64) Affine {
+	return Affine{sx, 0, 0, 0, sy, 0}
+}
+
+// Shear, x' = x + shx*y and y' = shy*x + y
+func ShearAffine(shx float64, shy float64) Affine {
+	return Affine{1, shx, 0, shy, 1, 0}
+}
+
+// Product m * n, the result applies n at first and then m
+func (m Affine) Mul(n Affine) Affine {
+	return Affine{
+		m[0]*n[0] + m[1]*n[3],
+		m[0]*n[1] + m[1]*n[4],
+		m[0]*n[2] + m[1]*n[5] + m[2],
+		m[3]*n[0] + m[4]*n[3],
+		m[3]*n[1] + m[4]*n[4],
+		m[3]*n[2] + m[4]*n[5] + m[5],
+	}
+}
+
+// Translate in the local coordinates of m, same as m.Mul(TranslateAffine())
+func (m Affine) Translate(tx float64, ty float64) Affine {
+	return m.Mul(TranslateAffine(tx, ty))
+}
+
+// Rotate in the local coordinates of m
+func (m Affine) Rotate(deg float64) Affine {
+	return m.Mul(RotateAffine(deg))
+}
+
+// Scale in the local coordinates of m
+func (m Affine) Scale(sx float64, sy float64) Affine {
+	return m.Mul(ScaleAffine(sx, sy))
+}
+
+// Shear in the local coordinates of m
+func (m Affine) Shear(shx float64, shy float64) Affine {
+	return m.Mul(ShearAffine(shx, shy))
+}
+
+// Inverse transform, false if m is not invertible
+func (m Affine) Invert() (Affine, bool) {
+	det := m[0]*m[4] - m[1]*m[3]
+	if det == 0 || math.IsNaN(det) {
+		return Affine{}, false
+	}
+	return Affine{
+		m[4] / det,
+		-m[1] / det,
+		(m[1]*m[5] - m[4]*m[2]) / det,
+		-m[3] / det,
+		m[0] / det,
+		(m[3]*m[2] - m[0]*m[5]) / det,
+	}, true
+}
+
+// Transform the point (x, y)
+func (m Affine) Apply(x float64, y float64) (float64, float64) {
+	return m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]
+}
+
+// Check m is the identity
+func (m Affine) IsIdentity() bool {
+	return m == IdentityAffine()
+}
+
+// Average scale factor of m
+func (m Affine) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[4] - m[1]*m[3]))
+}
+
+// Transform the point in sub-pixel coordinates
+func (m Affine) applyF(p fpoint) fpoint {
+	x, y := m.Apply(p.x-0.5, p.y-0.5)
+	return fpoint{x + 0.5, y + 0.5}
+}
+
+// Transform the polygons in sub-pixel coordinates
+func (m Affine) applyPolygons(polys [][]fpoint) [][]fpoint {
+	res := make([][]fpoint, len(polys))
+	for i, poly := range polys {
+		q := make([]fpoint, len(poly))
+		for j, pt := range poly {
+			q[j] = m.applyF(pt)
+		}
+		res[i] = q
+	}
+	return res
+}
+
+// Line style for the transformed path, the width and dashes are scaled
+// by the average scale factor of m.
+func (m Affine) applyStyle(style LineStyle) LineStyle {
+	s := m.scale()
+	style.Width *= s
+	style.DashOffset *= s
+	if style.Dash != nil {
+		dash := make([]float64, len(style.Dash))
+		for i, d := range style.Dash {
+			dash[i] = d * s
+		}
+		style.Dash = dash
+	}
+	return style
+}
+
+// Interpolation method for image drawing
+type Interpolation int
+
+const (
+	Nearest  Interpolation = iota // nearest neighbor
+	Bilinear                      // bilinear
+	Bicubic                       // bicubic (Catmull-Rom)
+	Lanczos                       // Lanczos3, for ResizeImage only
+)
+
+// Draw the PIXELARRAY transformed by m, the pixel (x, y) in p is mapped to
+// the point m.Apply(x, y) on the screen. The masked pixels are not drawn.
+func (wsd *WsDisplay) PutPixelArrayTransform(p PIXELARRAY, m Affine, interp Interpolation) error {
+	if err := wsd.checkPixelArray(p); err != nil {
+		return err
+	}
+	if interp == Nearest {
+		mask := p.GetMasks()
+		w := p.GetWidth()
+		return wsd.transformBlit(w, p.GetHeight(), m,
+			func(x int, y int, sx int, sy int) {
+				if mask[sx+sy*w] {
+					wsd.SetPixel(x, y, arrayPixel(p, sx, sy))
+				}
+			})
+	}
+	return wsd.drawRGBATransform(pixelArrayToRGBA(p, wsd.GetRGBmask(), wsd.GetByteOrder()), m, interp)
+}
+
+// Draw the image transformed by m, same as PutPixelArrayTransform.
+// The pixels are blended with the screen by the alpha of img.
+func (wsd *WsDisplay) DrawImageTransform(img image.Image, m Affine, interp Interpolation) error {
+	return wsd.drawRGBATransform(imageToRGBA(img), m, interp)
+}
+
+// Check the PIXELARRAY can be drawn to the display
+func (wsd *WsDisplay) checkPixelArray(p PIXELARRAY) error {
+	depth := 0
+	switch p.(type) {
+	case *PIXEL32ARRAY:
+		depth = 32
+	case *PIXEL24ARRAY:
+		depth = 24
+	case *PIXEL16ARRAY:
+		depth = 16
+	default:
+		return errors.New("Unsupported PixelType")
+	}
+	if wsd.GetDepth() != depth {
+		return errors.New("Unmatch PixelDepth")
+	}
+	return nil
+}
+
+// Call put(x, y, sx, sy) for each screen pixel (x, y) in the clip region
+// whose nearest source pixel (sx, sy) is in the w x h source.
+func (wsd *WsDisplay) transformBlit(w int, h int, m Affine, put func(x int, y int, sx int, sy int)) error {
+	inv, ok := m.Invert()
+	if !ok {
+		return errors.New("Transform is not invertible")
+	}
+	box := transformedBounds(w, h, m)
+	for _, c := range wsd.clipRects() {
+		r := box.Intersect(c)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				fx, fy := inv.Apply(float64(x), float64(y))
+				sx := int(math.Floor(fx + 0.5))
+				sy := int(math.Floor(fy + 0.5))
+				if sx < 0 || sy < 0 || sx >= w || sy >= h {
+					continue
+				}
+				put(x, y, sx, sy)
+			}
+		}
+	}
+	return nil
+}
+
+// Bounding box on the screen of the w x h source transformed by m
+func transformedBounds(w int, h int, m Affine) image.Rectangle {
+	xs := []float64{-0.5, float64(w) - 0.5}
+	ys := []float64{-0.5, float64(h) - 0.5}
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for _, sx := range xs {
+		for _, sy := range ys {
+			x, y := m.Apply(sx, sy)
+			minx = math.Min(minx, x)
+			maxx = math.Max(maxx, x)
+			miny = math.Min(miny, y)
+			maxy = math.Max(maxy, y)
+		}
+	}
+	return image.Rect(
+		int(math.Floor(minx)), int(math.Floor(miny)),
+		int(math.Ceil(maxx))+1, int(math.Ceil(maxy))+1)
+}
+
+// Convert PIXELARRAY to premultiplied RGBA, the masked pixels are transparent
+func pixelArrayToRGBA(p PIXELARRAY, rgbmask RGBmask, order ByteOrder) *image.RGBA {
+	w := p.GetWidth()
+	h := p.GetHeight()
+	mask := p.GetMasks()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !mask[x+y*w] {
+				continue
+			}
+			c := pixelColor(arrayPixel(p, x, y), rgbmask, order)
+			c.A = 255
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// Convert the image to premultiplied RGBA at the origin (0, 0)
+func imageToRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	if rgba, ok := img.(*image.RGBA); ok && b.Min == (image.Point{}) {
+		return rgba
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bb, a := img.At(x, y).RGBA()
+			i := dst.PixOffset(x-b.Min.X, y-b.Min.Y)
+			dst.Pix[i+0] = uint8(r >> 8)
+			dst.Pix[i+1] = uint8(g >> 8)
+			dst.Pix[i+2] = uint8(bb >> 8)
+			dst.Pix[i+3] = uint8(a >> 8)
+		}
+	}
+	return dst
+}
+
+// Draw premultiplied RGBA image transformed by m with interpolation
+func (wsd *WsDisplay) drawRGBATransform(src *image.RGBA, m Affine, interp Interpolation) error {
+	inv, ok := m.Invert()
+	if !ok {
+		return errors.New("Transform is not invertible")
+	}
+	w := src.Rect.Dx()
+	h := src.Rect.Dy()
+	box := transformedBounds(w, h, m)
+	for _, c := range wsd.clipRects() {
+		r := box.Intersect(c)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				fx, fy := inv.Apply(float64(x), float64(y))
+				if fx < -0.5 || fy < -0.5 ||
+					fx >= float64(w)-0.5 || fy >= float64(h)-0.5 {
+					continue
+				}
+				var s [4]float64
+				switch interp {
+				case Bilinear:
+					s = sampleBilinear(src, fx, fy)
+				case Bicubic, Lanczos:
+					s = sampleBicubic(src, fx, fy)
+				default:
+					s = samplePixel(src, int(math.Floor(fx+0.5)), int(math.Floor(fy+0.5)))
+				}
+				wsd.blendPremultiplied(x, y, s)
+			}
+		}
+	}
+	return nil
+}
+
+// Blend the premultiplied color s (0-255 per component) to (x, y)
+func (wsd *WsDisplay) blendPremultiplied(x int, y int, s [4]float64) {
+	a := clamp255(s[3])
+	if a == 0 {
+		return
+	}
+	c := color.RGBA{
+		R: uint8(clamp255(s[0] * 255 / float64(a))),
+		G: uint8(clamp255(s[1] * 255 / float64(a))),
+		B: uint8(clamp255(s[2] * 255 / float64(a))),
+		A: 255,
+	}
+	wsd.blendPixel(x, y, c, a)
+}
+
+func clamp255(f float64) uint32 {
+	if f <= 0 {
+		return 0
+	}
+	if f >= 255 {
+		return 255
+	}
+	return uint32(f + 0.5)
+}
+
+// Get the pixel of src as float, the coordinates are clamped to the edges
+func samplePixel(src *image.RGBA, x int, y int) [4]float64 {
+	w := src.Rect.Dx()
+	h := src.Rect.Dy()
+	if x < 0 {
+		x = 0
+	} else if x >= w {
+		x = w - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= h {
+		y = h - 1
+	}
+	i := y*src.Stride + x*4
+	return [4]float64{
+		float64(src.Pix[i+0]), float64(src.Pix[i+1]),
+		float64(src.Pix[i+2]), float64(src.Pix[i+3]),
+	}
+}
+
+func sampleBilinear(src *image.RGBA, fx float64, fy float64) (s [4]float64) {
+	x0 := math.Floor(fx)
+	y0 := math.Floor(fy)
+	tx := fx - x0
+	ty := fy - y0
+	ix := int(x0)
+	iy := int(y0)
+	p00 := samplePixel(src, ix, iy)
+	p10 := samplePixel(src, ix+1, iy)
+	p01 := samplePixel(src, ix, iy+1)
+	p11 := samplePixel(src, ix+1, iy+1)
+	for i := range s {
+		s[i] = (p00[i]*(1-tx)+p10[i]*tx)*(1-ty) +
+			(p01[i]*(1-tx)+p11[i]*tx)*ty
+	}
+	return
+}
+
+// Catmull-Rom cubic weight
+func cubicWeight(t float64) float64 {
+	t = math.Abs(t)
+	if t < 1 {
+		return 1.5*t*t*t - 2.5*t*t + 1
+	}
+	if t < 2 {
+		return -0.5*t*t*t + 2.5*t*t - 4*t + 2
+	}
+	return 0
+}
+
+func sampleBicubic(src *image.RGBA, fx float64, fy float64) (s [4]float64) {
+	x0 := math.Floor(fx)
+	y0 := math.Floor(fy)
+	ix := int(x0)
+	iy := int(y0)
+	var wx, wy [4]float64
+	for i := 0; i < 4; i++ {
+		wx[i] = cubicWeight(fx - (x0 + float64(i-1)))
+		wy[i] = cubicWeight(fy - (y0 + float64(i-1)))
+	}
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			p := samplePixel(src, ix+i-1, iy+j-1)
+			k := wx[i] * wy[j]
+			for c := range s {
+				s[c] += p[c] * k
+			}
+		}
+	}
+	// keep premultiplied color valid
+	for c := 0; c < 3; c++ {
+		if s[c] > s[3] {
+			s[c] = s[3]
+		}
+	}
+	return
+}
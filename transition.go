@@ -0,0 +1,273 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Slideshow transitions
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"math/rand"
+	"time"
+)
+
+type TransitionKind int
+
+const (
+	Crossfade  TransitionKind = iota // blend the images
+	WipeLeft                         // reveal the new image from the right to the left
+	WipeRight                        // from the left to the right
+	WipeUp                           // from the bottom to the top
+	WipeDown                         // from the top to the bottom
+	SlideLeft                        // the new image pushes the old one to the left
+	SlideRight                       // push to the right
+	SlideUp                          // push to the top
+	SlideDown                        // push to the bottom
+	Dissolve                         // reveal the new image pixel by pixel randomly
+)
+
+// Interval of the transition frames
+const transitionFrame = time.Second / 30
+
+// Change the area at (x, y) from the image from to the image to in
+// duration d. If from is nil, the current screen is used. Each frame
+// writes only the changed area. If ctx is canceled, to is drawn at once
+// and ctx.Err() is returned.
+func (wsd *WsDisplay) Transition(ctx context.Context, x int, y int, from PIXELARRAY, to PIXELARRAY,
+	kind TransitionKind, d time.Duration) error {
+	if err := wsd.checkPixelArray(to); err != nil {
+		return err
+	}
+	w := to.GetWidth()
+	h := to.GetHeight()
+	if from == nil {
+		var err error
+		from, err = wsd.GetPixelArray(image.Rect(x, y, x+w, y+h))
+		if err != nil {
+			return err
+		}
+	} else {
+		if err := wsd.checkPixelArray(from); err != nil {
+			return err
+		}
+		if from.GetWidth() != w || from.GetHeight() != h {
+			return errors.New("Unmatch PixelArray size")
+		}
+	}
+
+	var frame func(t float64)
+	switch kind {
+	case Crossfade:
+		var err error
+		frame, err = wsd.crossfadeFrames(x, y, from, to)
+		if err != nil {
+			return err
+		}
+	case WipeLeft, WipeRight, WipeUp, WipeDown:
+		frame = wsd.wipeFrames(x, y, to, kind)
+	case SlideLeft, SlideRight, SlideUp, SlideDown:
+		frame = wsd.slideFrames(x, y, from, to, kind)
+	case Dissolve:
+		frame = wsd.dissolveFrames(x, y, to)
+	default:
+		return errors.New("Unknown transition")
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	start := time.Now()
+	next := start
+	for {
+		t := 1.0
+		if d > 0 {
+			t = float64(time.Since(start)) / float64(d)
+		}
+		if t >= 1 {
+			frame(1)
+			return nil
+		}
+		frame(t)
+		next = next.Add(transitionFrame)
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			frame(1)
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Blend from and to in the work array by 256 levels. The pixels are
+// encoded by the tables of the channels, as the encoded pixel is the OR
+// of the encoded channels.
+func (wsd *WsDisplay) crossfadeFrames(x int, y int, from PIXELARRAY, to PIXELARRAY) (func(float64), error) {
+	rgbmask, order := wsd.GetRGBmask(), wsd.GetByteOrder()
+	w := to.GetWidth()
+	h := to.GetHeight()
+	work, err := wsd.newPixelArraySize(w, h)
+	if err != nil {
+		return nil, err
+	}
+	buf, bpp := pixelArrayBytes(work)
+	var red, green, blue [256][4]uint8
+	for v := 0; v < 256; v++ {
+		c := uint8(v)
+		setBytesColor(red[v][:bpp], color.RGBA{c, 0, 0, 255}, rgbmask, order)
+		setBytesColor(green[v][:bpp], color.RGBA{0, c, 0, 255}, rgbmask, order)
+		setBytesColor(blue[v][:bpp], color.RGBA{0, 0, c, 255}, rgbmask, order)
+	}
+	a := pixelArrayToRGBA(from, rgbmask, order).Pix
+	b := pixelArrayToRGBA(to, rgbmask, order).Pix
+	all := image.Rect(0, 0, w, h)
+	last := -1
+	return func(t float64) {
+		if t >= 1 {
+			wsd.putPixelArrayRect(image.Pt(x, y), to, all)
+			return
+		}
+		level := int(t*256 + 0.5)
+		if level == last {
+			return
+		}
+		last = level
+		for i, j := 0, 0; j < len(buf); i, j = i+4, j+bpp {
+			r := (int(a[i])*(256-level) + int(b[i])*level) >> 8
+			g := (int(a[i+1])*(256-level) + int(b[i+1])*level) >> 8
+			bl := (int(a[i+2])*(256-level) + int(b[i+2])*level) >> 8
+			for k := 0; k < bpp; k++ {
+				buf[j+k] = red[r][k] | green[g][k] | blue[bl][k]
+			}
+		}
+		wsd.putPixelArrayRect(image.Pt(x, y), work, all)
+	}, nil
+}
+
+// Draw the newly revealed strip of to
+func (wsd *WsDisplay) wipeFrames(x int, y int, to PIXELARRAY, kind TransitionKind) func(float64) {
+	w := to.GetWidth()
+	h := to.GetHeight()
+	done := 0
+	return func(t float64) {
+		size := w
+		if kind == WipeUp || kind == WipeDown {
+			size = h
+		}
+		n := int(t * float64(size))
+		if n > size {
+			n = size
+		}
+		if n <= done {
+			return
+		}
+		var r image.Rectangle
+		switch kind {
+		case WipeLeft:
+			r = image.Rect(w-n, 0, w-done, h)
+		case WipeRight:
+			r = image.Rect(done, 0, n, h)
+		case WipeUp:
+			r = image.Rect(0, h-n, w, h-done)
+		case WipeDown:
+			r = image.Rect(0, done, w, n)
+		}
+		done = n
+		wsd.putPixelArrayRect(image.Pt(x, y).Add(r.Min), to, r)
+	}
+}
+
+// Move both images by the offset
+func (wsd *WsDisplay) slideFrames(x int, y int, from PIXELARRAY, to PIXELARRAY, kind TransitionKind) func(float64) {
+	w := to.GetWidth()
+	h := to.GetHeight()
+	last := -1
+	return func(t float64) {
+		size := w
+		if kind == SlideUp || kind == SlideDown {
+			size = h
+		}
+		o := int(t * float64(size))
+		if o > size {
+			o = size
+		}
+		if o == last {
+			return
+		}
+		last = o
+		// areas in from and to, and where they are drawn in the area
+		var fr, tr image.Rectangle
+		var fp, tp image.Point
+		switch kind {
+		case SlideLeft:
+			fr, fp = image.Rect(o, 0, w, h), image.Pt(0, 0)
+			tr, tp = image.Rect(0, 0, o, h), image.Pt(w-o, 0)
+		case SlideRight:
+			fr, fp = image.Rect(0, 0, w-o, h), image.Pt(o, 0)
+			tr, tp = image.Rect(w-o, 0, w, h), image.Pt(0, 0)
+		case SlideUp:
+			fr, fp = image.Rect(0, o, w, h), image.Pt(0, 0)
+			tr, tp = image.Rect(0, 0, w, o), image.Pt(0, h-o)
+		case SlideDown:
+			fr, fp = image.Rect(0, 0, w, h-o), image.Pt(0, o)
+			tr, tp = image.Rect(0, h-o, w, h), image.Pt(0, 0)
+		}
+		org := image.Pt(x, y)
+		wsd.putPixelArrayRect(org.Add(fp), from, fr)
+		wsd.putPixelArrayRect(org.Add(tp), to, tr)
+	}
+}
+
+// Draw the pixels of to in random order
+func (wsd *WsDisplay) dissolveFrames(x int, y int, to PIXELARRAY) func(float64) {
+	w := to.GetWidth()
+	n := w * to.GetHeight()
+	order := make([]int32, n)
+	for i := range order {
+		order[i] = int32(i)
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rnd.Shuffle(n, func(i int, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+	done := 0
+	return func(t float64) {
+		k := int(t * float64(n))
+		if k > n {
+			k = n
+		}
+		for _, i := range order[done:k] {
+			px := int(i) % w
+			py := int(i) / w
+			wsd.SetPixel(x+px, y+py, arrayPixel(to, px, py))
+		}
+		if k > done {
+			done = k
+		}
+	}
+}
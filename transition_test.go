@@ -0,0 +1,138 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for the transitions
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// w x h arrays of the gradients in the different directions
+func testTransitionArrays(t testing.TB, wsd *WsDisplay, w int, h int) (PIXELARRAY, PIXELARRAY) {
+	from := image.NewRGBA(image.Rect(0, 0, w, h))
+	to := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			from.SetRGBA(x, y, color.RGBA{uint8(x * 255 / w), 40, uint8(y * 255 / h), 255})
+			to.SetRGBA(x, y, color.RGBA{200, uint8(y * 255 / h), uint8(x * 255 / w), 255})
+		}
+	}
+	a, err := wsd.NewScaledPixelArray(from, w, h, Nearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := wsd.NewScaledPixelArray(to, w, h, Nearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestCrossfadeFrames(t *testing.T) {
+	for _, depth := range []int{16, 24, 32} {
+		for _, order := range []ByteOrder{LittleEndian, BigEndian} {
+			wsd := newTestDisplay(t, 16, 8, depth)
+			wsd.SetByteOrder(order)
+			from, to := testTransitionArrays(t, wsd, 16, 8)
+			frame, err := wsd.crossfadeFrames(0, 0, from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frame(0.25)
+
+			// blend the decoded colors and encode them one by one
+			want := newTestDisplay(t, 16, 8, depth)
+			want.SetByteOrder(order)
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 16; x++ {
+					a := wsd.pixelColor(arrayPixel(from, x, y))
+					b := wsd.pixelColor(arrayPixel(to, x, y))
+					want.SetPixel(x, y, want.NewPixel(color.RGBA{
+						uint8((int(a.R)*192 + int(b.R)*64) >> 8),
+						uint8((int(a.G)*192 + int(b.G)*64) >> 8),
+						uint8((int(a.B)*192 + int(b.B)*64) >> 8),
+						255,
+					}))
+				}
+			}
+			if !bytes.Equal(wsd.GetBuffer(), want.GetBuffer()) {
+				t.Errorf("depth %d order %d: blended pixels differ", depth, order)
+			}
+
+			frame(1)
+			ref := newTestDisplay(t, 16, 8, depth)
+			ref.SetByteOrder(order)
+			ref.PutPixelArray(0, 0, to)
+			if !bytes.Equal(wsd.GetBuffer(), ref.GetBuffer()) {
+				t.Errorf("depth %d order %d: last frame is not the new image", depth, order)
+			}
+		}
+	}
+}
+
+func TestCrossfadeUnsupportedDepth(t *testing.T) {
+	wsd := newTestDisplay(t, 4, 4, 32)
+	from, to := testTransitionArrays(t, wsd, 4, 4)
+	wsd.info.bitsperpixel = 8
+	if _, err := wsd.crossfadeFrames(0, 0, from, to); err == nil {
+		t.Error("no error for 8bpp")
+	}
+}
+
+func TestTransitionCanceled(t *testing.T) {
+	for _, kind := range []TransitionKind{Crossfade, WipeLeft, SlideDown, Dissolve} {
+		wsd := newTestDisplay(t, 16, 8, 32)
+		from, to := testTransitionArrays(t, wsd, 16, 8)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := wsd.Transition(ctx, 0, 0, from, to, kind, 0); err != nil {
+			t.Errorf("kind %d: %v", kind, err)
+		}
+		ref := newTestDisplay(t, 16, 8, 32)
+		ref.PutPixelArray(0, 0, to)
+		if !bytes.Equal(wsd.GetBuffer(), ref.GetBuffer()) {
+			t.Errorf("kind %d: the new image is not drawn", kind)
+		}
+	}
+}
+
+func BenchmarkCrossfadeFrame(b *testing.B) {
+	wsd := newTestDisplay(b, 320, 240, 16)
+	from, to := testTransitionArrays(b, wsd, 320, 240)
+	frame, err := wsd.crossfadeFrames(0, 0, from, to)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame(float64(i%255+1) / 256)
+	}
+}
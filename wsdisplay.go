@@ -30,6 +30,8 @@ package gowsdisplay
 
 import (
 	"errors"
+	"image"
+	"image/color"
 	"math"
 	"syscall"
 	"unsafe"
@@ -104,8 +106,23 @@ type WsDisplay struct {
 	info FBinfo // fbinfo struct
 	addr []byte // display memory (VRAM) address for mmap
 	dev  string // device name
+	mem  bool   // in-memory buffer, not a device
+
+	clip      []image.Rectangle   // clip region, nil is whole screen
+	clipStack [][]image.Rectangle // saved clip regions by PushClip
+	rotation  Rotation            // rotation of the logical screen
+	order     ByteOrder           // byte order of the pixel data
 }
 
+// Byte order of the pixel data in the framebuffer
+type ByteOrder uint32
+
+const (
+	HostOrder    ByteOrder = iota // same as the CPU
+	LittleEndian                  // least significant byte first
+	BigEndian                     // most significant byte first
+)
+
 type RGBmask struct {
 	Red_offset   uint32 // Red offset bits from the right
 	Red_size     uint32 // Red size in bits
@@ -139,8 +156,42 @@ func NewWsDisplay(dev string) *WsDisplay {
 	return wsd
 }
 
+// Create in-memory display, it has same operations as a framebuffer
+// and it's useful for off-screen drawing or checking the drawing results.
+// Open, InitGraphics and Close do nothing for it.
+// The depth must be 16, 24 or 32. The pixels are in the CPU byte
+// order, SetByteOrder changes it.
+func NewMemDisplay(width int, height int, depth int, rgbmask RGBmask) (*WsDisplay, error) {
+	switch depth {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("Unsupported PixelDepth")
+	}
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("Invalid display size")
+	}
+	wsd := new(WsDisplay)
+	wsd.fd = -1
+	wsd.mem = true
+	wsd.dev = "memory"
+	wsd.info.width = uint32(width)
+	wsd.info.height = uint32(height)
+	wsd.info.bitsperpixel = uint32(depth)
+	wsd.info.stride = uint32(width * ((depth + 7) / 8))
+	wsd.info.pixeltype = FBRGB
+	wsd.info.rgbmask = rgbmask
+	wsd.order = HostOrder.resolve()
+	wsd.info.size = uint64(wsd.info.stride) * uint64(height)
+	wsd.info.flags = FBVRAM_IS_RAM
+	wsd.addr = make([]byte, wsd.info.size)
+	return wsd, nil
+}
+
 // Open display and get fbinfo
 func (wsd *WsDisplay) Open() (err error) {
+	if wsd.mem {
+		return nil
+	}
 
 	wsd.fd, err = syscall.Open(wsd.dev, syscall.O_RDWR, 0)
 	if err != nil {
@@ -153,6 +204,9 @@ func (wsd *WsDisplay) Open() (err error) {
 
 // Close display and set to text emul mode
 func (wsd *WsDisplay) Close() error {
+	if wsd.mem {
+		return nil
+	}
 	if wsd.addr != nil {
 		wsd.unmapFB()
 	}
@@ -168,6 +222,32 @@ func (wsd *WsDisplay) GetRGBmask() RGBmask {
 	return wsd.info.rgbmask
 }
 
+// Get the byte order of the pixel data, it's never HostOrder
+func (wsd *WsDisplay) GetByteOrder() ByteOrder {
+	return wsd.order.resolve()
+}
+
+// Set the byte order of the pixel data. Open sets the order of the
+// fbinfo masks, set it for the framebuffer whose byte order differs.
+func (wsd *WsDisplay) SetByteOrder(order ByteOrder) {
+	wsd.order = order.resolve()
+}
+
+// Get the color of the pixel of the display
+func (wsd *WsDisplay) pixelColor(p PIXEL) color.RGBA {
+	return pixelColor(p, wsd.GetRGBmask(), wsd.GetByteOrder())
+}
+
+// Set the color to the pixel of the display
+func (wsd *WsDisplay) setPixelColor(p PIXEL, c color.Color) {
+	setPixelColor(p, c, wsd.GetRGBmask(), wsd.GetByteOrder())
+}
+
+// Store the image to the PIXELARRAY of the display
+func (wsd *WsDisplay) storeImage(p PIXELARRAY, img image.Image) {
+	storeImage(p, img, wsd.GetRGBmask(), wsd.GetByteOrder())
+}
+
 func (wsd *WsDisplay) GetBufferAddr() *byte {
 	return &wsd.addr[0]
 }
@@ -209,11 +289,19 @@ func (wsd *WsDisplay) GetOffset() uint64 {
 	return wsd.info.offset
 }
 
+// Get the width of the logical screen, see SetRotation
 func (wsd *WsDisplay) GetWidth() int {
+	if wsd.rotation == Rotate90 || wsd.rotation == Rotate270 {
+		return int(wsd.info.height)
+	}
 	return int(wsd.info.width)
 }
 
+// Get the height of the logical screen, see SetRotation
 func (wsd *WsDisplay) GetHeight() int {
+	if wsd.rotation == Rotate90 || wsd.rotation == Rotate270 {
+		return int(wsd.info.width)
+	}
 	return int(wsd.info.height)
 }
 
@@ -226,7 +314,7 @@ func (wsd *WsDisplay) GetStride() int {
 }
 
 func (wsd *WsDisplay) GetPixelStride() int {
-	return int(wsd.info.stride/(wsd.info.bitsperpixel/8))
+	return int(wsd.info.stride / (wsd.info.bitsperpixel / 8))
 }
 
 func (wsd *WsDisplay) GetDepth() int {
@@ -237,6 +325,9 @@ func (wsd *WsDisplay) getFBinfo() error {
 	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(wsd.fd),
 		FBGETFBINFO, uintptr(unsafe.Pointer(&wsd.info)))
 	if errno == 0 {
+		// the fbinfo has no byte order, the drivers give the masks
+		// for the pixel value read by the CPU
+		wsd.order = HostOrder.resolve()
 		return nil
 	}
 	return errno
@@ -268,6 +359,9 @@ func (wsd *WsDisplay) setMode(mode int) error {
 
 // set to dumbfb mode and mmap framebuffer
 func (wsd *WsDisplay) InitGraphics() error {
+	if wsd.mem {
+		return nil
+	}
 	err := wsd.setMode(FBMODE_DUMBFB)
 	if err != nil {
 		return err
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Yasushi Oshima All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Tests for WsDisplay on memory
+
+// +build netbsd
+
+package gowsdisplay
+
+import (
+	"image/color"
+	"testing"
+)
+
+// RGB masks used by the tests for each depth
+func testRGBmask(depth int) RGBmask {
+	switch depth {
+	case 32, 24:
+		return RGBmask{
+			Red_offset: 16, Red_size: 8,
+			Green_offset: 8, Green_size: 8,
+			Blue_offset: 0, Blue_size: 8,
+		}
+	case 16:
+		return RGBmask{
+			Red_offset: 11, Red_size: 5,
+			Green_offset: 5, Green_size: 6,
+			Blue_offset: 0, Blue_size: 5,
+		}
+	}
+	return RGBmask{}
+}
+
+func newTestDisplay(t testing.TB, width int, height int, depth int) *WsDisplay {
+	wsd, err := NewMemDisplay(width, height, depth, testRGBmask(depth))
+	if err != nil {
+		t.Fatalf("NewMemDisplay(%d, %d, %d): %v", width, height, depth, err)
+	}
+	return wsd
+}
+
+func TestNewMemDisplay(t *testing.T) {
+	for _, depth := range []int{16, 24, 32} {
+		wsd := newTestDisplay(t, 7, 5, depth)
+		if wsd.GetDepth() != depth || wsd.GetWidth() != 7 || wsd.GetHeight() != 5 {
+			t.Errorf("depth %d: got %dx%dx%d", depth,
+				wsd.GetWidth(), wsd.GetHeight(), wsd.GetDepth())
+		}
+		if len(wsd.GetBuffer()) != 7*5*(depth/8) {
+			t.Errorf("depth %d: buffer size %d", depth, len(wsd.GetBuffer()))
+		}
+	}
+	// 8bpp has no NewPixel and PIXELARRAY
+	for _, depth := range []int{0, 1, 4, 8, 15, 64, -8} {
+		if _, err := NewMemDisplay(4, 4, depth, RGBmask{}); err == nil {
+			t.Errorf("depth %d: no error", depth)
+		}
+	}
+	for _, size := range [][2]int{{0, 4}, {4, 0}, {-1, 4}} {
+		if _, err := NewMemDisplay(size[0], size[1], 32, testRGBmask(32)); err == nil {
+			t.Errorf("size %v: no error", size)
+		}
+	}
+}
+
+func TestPixelRoundTrip(t *testing.T) {
+	c := color.RGBA{0xff, 0x80, 0x00, 0xff}
+	for _, depth := range []int{16, 24, 32} {
+		wsd := newTestDisplay(t, 4, 4, depth)
+		wsd.SetPixel(1, 2, wsd.NewPixel(c))
+		got := wsd.pixelColor(wsd.GetPixel(1, 2))
+		want := wsd.pixelColor(wsd.NewPixel(c))
+		if got != want {
+			t.Errorf("depth %d: got %v, want %v", depth, got, want)
+		}
+	}
+}